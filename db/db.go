@@ -1,12 +1,17 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log"
 	"nbeat-api/helper"
+	"nbeat-api/models"
+	"slices"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -36,3 +41,138 @@ func Connect() *mongo.Client {
 	return client
 
 }
+
+// mergeDuplicateQueues merges queues of the same channel, which could be created concurrently
+// before queue.channel_id was unique, into the one with the highest version
+func mergeDuplicateQueues(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("queue")
+
+	cursor, err := collection.Aggregate(ctx, []bson.M{
+		{"$group": bson.M{
+			"_id":   "$channel_id",
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var duplicates []struct {
+		Ids []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+
+	for _, duplicate := range duplicates {
+		cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$in": duplicate.Ids}})
+		if err != nil {
+			return err
+		}
+
+		var queues []models.Queue
+		if err := cursor.All(ctx, &queues); err != nil {
+			return err
+		}
+
+		merged, removed := mergeQueues(queues)
+
+		if _, err := collection.ReplaceOne(ctx, bson.M{"_id": merged.Id}, merged); err != nil {
+			return err
+		}
+
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": removed}}); err != nil {
+			return err
+		}
+
+		log.Printf("merged %d duplicate queues of channel %s", len(removed), merged.ChannelId.Hex())
+	}
+
+	return nil
+}
+
+// mergeQueues appends upcoming songs of other queues to the queue with the highest version
+// and returns it together with ids of the other queues. Songs the others already played are dropped.
+func mergeQueues(queues []models.Queue) (models.Queue, []primitive.ObjectID) {
+	slices.SortStableFunc(queues, func(a, b models.Queue) int {
+		return cmp.Compare(b.Version, a.Version)
+	})
+
+	merged := queues[0]
+	first, startTime := merged.Upcoming(merged.Now())
+
+	queued := make(map[primitive.ObjectID]struct{}, len(merged.Songs))
+	for _, song := range merged.Songs {
+		queued[song.Id] = struct{}{}
+	}
+
+	removed := make([]primitive.ObjectID, 0, len(queues)-1)
+	for _, queue := range queues[1:] {
+		removed = append(removed, queue.Id)
+
+		for _, song := range queue.UpcomingSongs(queue.Now()) {
+			if _, exists := queued[song.Id]; exists {
+				continue
+			}
+
+			queued[song.Id] = struct{}{}
+			merged.Songs = append(merged.Songs, song)
+		}
+	}
+
+	merged.Reschedule(first, startTime)
+	merged.Version++
+
+	return merged, removed
+}
+
+// CreateIndexes creates indexes used by queries of the api
+func CreateIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// unique index can't be created while duplicates exist
+	if err := mergeDuplicateQueues(ctx, db); err != nil {
+		return err
+	}
+
+	_, err := db.Collection("queue").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "channel_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("song_catalog").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "song_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("song_like").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "channel_id", Value: 1}, {Key: "song.song_start_time", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "song.id", Value: 1}, {Key: "user", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("song_history").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "channel_id", Value: 1}, {Key: "song_start_time", Value: -1}, {Key: "id", Value: -1}}},
+		{Keys: bson.D{{Key: "channel_id", Value: 1}, {Key: "song_id", Value: 1}, {Key: "song_start_time", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "channel_id", Value: 1}, {Key: "id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+
+	return err
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"nbeat-api/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newSong(startTime int64, duration float64) models.Song {
+	return models.Song{
+		Id:            primitive.NewObjectID(),
+		SongId:        primitive.NewObjectID().Hex(),
+		Duration:      duration,
+		SongStartTime: startTime,
+	}
+}
+
+func TestMergeQueues(t *testing.T) {
+	now := time.Now().UnixMilli()
+
+	current := newSong(now-10_000, 60)
+	upcoming := newSong(current.EndTime(), 60)
+	primary := models.Queue{
+		Id:      primitive.NewObjectID(),
+		Songs:   []models.Song{current, upcoming},
+		Version: 5,
+	}
+
+	playedElsewhere := newSong(now-120_000, 60)
+	playingElsewhere := newSong(now-5_000, 60)
+	upcomingElsewhere := newSong(playingElsewhere.EndTime(), 30)
+	duplicate := models.Queue{
+		Id:      primitive.NewObjectID(),
+		Songs:   []models.Song{playedElsewhere, playingElsewhere, upcomingElsewhere, upcoming},
+		Version: 2,
+	}
+
+	merged, removed := mergeQueues([]models.Queue{duplicate, primary})
+
+	if merged.Id != primary.Id {
+		t.Fatalf("merged into %s, want queue with the highest version %s", merged.Id.Hex(), primary.Id.Hex())
+	}
+
+	if len(removed) != 1 || removed[0] != duplicate.Id {
+		t.Fatalf("removed = %v, want [%s]", removed, duplicate.Id.Hex())
+	}
+
+	want := []primitive.ObjectID{current.Id, upcoming.Id, upcomingElsewhere.Id}
+	if len(merged.Songs) != len(want) {
+		t.Fatalf("merged queue has %d songs, want %d", len(merged.Songs), len(want))
+	}
+
+	for i, song := range merged.Songs {
+		if song.Id != want[i] {
+			t.Errorf("song %d = %s, want %s", i, song.Id.Hex(), want[i].Hex())
+		}
+
+		if i > 0 && song.SongStartTime != merged.Songs[i-1].EndTime() {
+			t.Errorf("song %d starts at %d, want end of the previous one %d", i, song.SongStartTime, merged.Songs[i-1].EndTime())
+		}
+	}
+
+	if merged.Version != primary.Version+1 {
+		t.Errorf("version = %d, want %d", merged.Version, primary.Version+1)
+	}
+}
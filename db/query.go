@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"nbeat-api/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FetchPlaylist returns the playlist with the given id
+func FetchPlaylist(c context.Context, db *mongo.Database, playlistId string) (models.Playlist, error) {
+	playlistObjId, err := primitive.ObjectIDFromHex(playlistId)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	var playlist models.Playlist
+	if err := db.Collection("playlist").FindOne(c, bson.M{"_id": playlistObjId}).Decode(&playlist); err != nil {
+		return models.Playlist{}, err
+	}
+
+	return playlist, nil
+}
+
+// ChannelExists tells whether the channel exists and isn't deleted
+func ChannelExists(c context.Context, db *mongo.Database, channelObjId primitive.ObjectID) (bool, error) {
+	filter := bson.M{
+		"_id":        channelObjId,
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	count, err := db.Collection("channel").CountDocuments(c, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
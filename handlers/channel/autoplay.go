@@ -0,0 +1,139 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"log"
+	"nbeat-api/models"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// SystemAuthor is the author of messages sent by the server
+	SystemAuthor = "system"
+
+	// How long before the queue runs out the next song is added
+	autoplayLead = 10 * time.Second
+
+	// Maximum number of songs sources return to choose from
+	autoplayCandidates = 20
+)
+
+// autoplaySource returns songs which can be played next in the channel, in order of preference
+type autoplaySource func(h *Handler, channel models.Channel, queue models.Queue) ([]models.Song, error)
+
+var autoplaySources = map[string]autoplaySource{
+	models.AutoplayHistory:  historyAutoplaySource,
+	models.AutoplayPlaylist: playlistAutoplaySource,
+	models.AutoplayLiked:    likedAutoplaySource,
+}
+
+func historyAutoplaySource(h *Handler, channel models.Channel, queue models.Queue) ([]models.Song, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"channel_id": queue.ChannelId}},
+		{"$group": bson.M{
+			"_id":  "$song_id",
+			"song": bson.M{"$first": "$$ROOT"},
+		}},
+		{"$sample": bson.M{"size": autoplayCandidates}},
+		{"$replaceRoot": bson.M{"newRoot": "$song"}},
+	}
+
+	cursor, err := h.Db.Collection("song_history").Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.HistorySong
+	if err := cursor.All(context.Background(), &entries); err != nil {
+		return nil, err
+	}
+
+	songs := make([]models.Song, 0, len(entries))
+	for _, entry := range entries {
+		songs = append(songs, entry.Song)
+	}
+
+	return songs, nil
+}
+
+// StartAutoplayWorker periodically adds songs to channels with listeners whose queue is about to run out
+func (h *Handler) StartAutoplayWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, channelId := range ConnectedChannels() {
+			if err := h.autoplay(channelId); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+func (h *Handler) autoplay(channelId string) error {
+	channel, err := h.fetchChannel(context.Background(), channelId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	source, exists := autoplaySources[channel.Settings.Autoplay.Source]
+	if !exists {
+		return nil
+	}
+
+	queue, err := h.fetchQueue(channelId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if queue.IsPaused() {
+		return nil
+	}
+
+	if len(queue.Songs) > 0 && queue.Songs[len(queue.Songs)-1].EndTime()-queue.Now() > autoplayLead.Milliseconds() {
+		return nil
+	}
+
+	candidates, err := source(h, channel, queue)
+	if err != nil {
+		return err
+	}
+
+	for _, song := range candidates {
+		if err := h.checkReplay(queue, song, channel.Settings.ReplayCooldown); err != nil {
+			var rejection *Rejection
+			if errors.As(err, &rejection) {
+				continue
+			}
+			return err
+		}
+
+		song, err = h.PlaySong(song.NewEntry(), channelId)
+
+		// try another song if this one can't be played now
+		var rejection *Rejection
+		if errors.As(err, &rejection) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		message, err := h.announceSong(song, channelId, SystemAuthor)
+		if err != nil {
+			return err
+		}
+
+		return broadcastMessage(websocket.TextMessage, message, channelId)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package channel
+
+import (
+	"nbeat-api/middleware/auth"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// blocklistFields maps blocklist types from request paths to fields of the channel
+var blocklistFields = map[string]string{
+	"songs":     "blocklist.song_ids",
+	"uploaders": "blocklist.uploaders",
+	"keywords":  "blocklist.keywords",
+}
+
+func (h *Handler) GetBlocklist(c *gin.Context) {
+	channel, err := h.fetchChannel(c, c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if !channel.IsModerator(auth.ExtractClaimsFromContext(c).Id) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel.Blocklist)
+}
+
+// AddToBlocklist adds value from the body to the blocklist of type given in the path (songs, uploaders or keywords)
+func (h *Handler) AddToBlocklist(c *gin.Context) {
+	field, exists := blocklistFields[c.Param("type")]
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+
+	if err := c.BindJSON(&body); err != nil || strings.TrimSpace(body.Value) == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	h.updateModeratedChannel(c, bson.M{"$addToSet": bson.M{field: strings.TrimSpace(body.Value)}})
+}
+
+func (h *Handler) RemoveFromBlocklist(c *gin.Context) {
+	field, exists := blocklistFields[c.Param("type")]
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	h.updateModeratedChannel(c, bson.M{"$pull": bson.M{field: c.Param("value")}})
+}
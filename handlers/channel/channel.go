@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
+	"math"
+	"nbeat-api/db"
 	"nbeat-api/helper"
+	"nbeat-api/media"
 	"nbeat-api/middleware/auth"
 	"nbeat-api/models"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,17 +31,44 @@ var (
 	clientRegistry = struct {
 		m     sync.Mutex
 		conns map[string][]*websocket.Conn
-	}{conns: make(map[string][]*websocket.Conn)}
+		// users holds id of the authorized user for each connection
+		users map[*websocket.Conn]string
+	}{
+		conns: make(map[string][]*websocket.Conn),
+		users: make(map[*websocket.Conn]string),
+	}
 )
 
 const (
 	MessageTypeAuth       = "auth"
 	MessageTypeChangeTime = "time"
 	MessageTypeText       = "text"
+	MessageTypeSkip       = "skip"
+	MessageTypeRemove     = "remove"
+	MessageTypeMove       = "move"
+	MessageTypePlayNext   = "next"
+	MessageTypePause      = "pause"
+	MessageTypeResume     = "resume"
+	MessageTypePing       = "ping"
+	MessageTypeLike       = "like"
+	MessageTypeApprove    = "approve"
+	MessageTypeReject     = "reject"
 )
 
+// PlayCommand prefixes text messages which enqueue the top search result of the rest of the message
+const PlayCommand = "/play "
+
 type Handler struct {
 	Db *mongo.Database
+	// Providers resolve songs from urls posted in the channel
+	Providers *media.Registry
+	// Catalog tracks how many times songs were played
+	Catalog *media.Catalog
+	// Search keeps recent search results
+	Search *media.SearchCache
+	// RestoreWindow is how long a deleted channel can still be restored by its owner
+	// before it gets purged
+	RestoreWindow time.Duration
 }
 
 var upgrader = websocket.Upgrader{
@@ -64,6 +94,51 @@ func RemoveConnection(conn *websocket.Conn, channelId string) {
 			break
 		}
 	}
+	delete(clientRegistry.users, conn)
+	clientRegistry.m.Unlock()
+}
+
+func SetConnectionUser(conn *websocket.Conn, userId string) {
+	clientRegistry.m.Lock()
+	clientRegistry.users[conn] = userId
+	clientRegistry.m.Unlock()
+}
+
+// CountListeners returns the number of distinct authorized users connected to the channel
+func CountListeners(channelId string) int {
+	clientRegistry.m.Lock()
+	defer clientRegistry.m.Unlock()
+
+	listeners := make(map[string]struct{})
+	for _, conn := range clientRegistry.conns[channelId] {
+		if userId := clientRegistry.users[conn]; userId != "" {
+			listeners[userId] = struct{}{}
+		}
+	}
+
+	return len(listeners)
+}
+
+// ConnectedChannels returns ids of channels with at least one connection
+func ConnectedChannels() []string {
+	clientRegistry.m.Lock()
+	defer clientRegistry.m.Unlock()
+
+	channels := make([]string, 0, len(clientRegistry.conns))
+	for channelId, conns := range clientRegistry.conns {
+		if len(conns) > 0 {
+			channels = append(channels, channelId)
+		}
+	}
+
+	return channels
+}
+
+func CloseConnections(channelId string) {
+	clientRegistry.m.Lock()
+	for _, conn := range clientRegistry.conns[channelId] {
+		conn.Close()
+	}
 	clientRegistry.m.Unlock()
 }
 
@@ -73,6 +148,11 @@ func (h *Handler) Channel(c *gin.Context) {
 
 	channelId := c.Param("id")
 
+	if !h.channelExists(c, channelId) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Print("Error during connection upgradation:", err)
@@ -93,8 +173,15 @@ func (h *Handler) Channel(c *gin.Context) {
 			break
 		}
 
-		if err := h.handleMessage(messageType, message, channelId, &userId); err != nil {
+		if err := h.handleMessage(conn, messageType, message, channelId, &userId); err != nil {
 			log.Println(err)
+
+			var rejection *Rejection
+			if errors.As(err, &rejection) {
+				if err := sendRejection(conn, rejection); err != nil {
+					log.Println(err)
+				}
+			}
 		}
 
 	}
@@ -108,20 +195,40 @@ func (h *Handler) handleAuthMessage(message string, userId *string) error {
 	return errors.New("invalid auth token")
 }
 
-func (h *Handler) handleTimeMessage(message string, channelId string) error {
-	time, err := strconv.ParseFloat(message, 8)
+// handleTimeMessage seeks to the position (in seconds) within the current song
+// and returns the resulting playback status
+// handleTimeMessage seeks within the current song, only moderators and DJs can do it
+// as seeking to the end would skip the song without a vote
+func (h *Handler) handleTimeMessage(message string, channelId, userId string) ([]byte, error) {
+	position, err := strconv.ParseFloat(message, 64)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if math.IsNaN(position) || math.IsInf(position, 0) {
+		return nil, errors.New("invalid position")
+	}
+
+	channel, err := h.fetchChannel(context.Background(), channelId)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	return h.ChangeTime(time, channelObjId)
+	if !channel.IsModerator(userId) && !channel.IsDJ(userId) {
+		return nil, errForbidden
+	}
+
+	queue, err := h.SeekTo(channelId, int64(position*1000))
+	if err != nil {
+		return nil, err
+	}
+
+	return playbackMessage(queue)
 }
-func (h *Handler) handleMessage(messageType int, message []byte, channelId string, userId *string) error {
+
+func (h *Handler) handleMessage(conn *websocket.Conn, messageType int, message []byte, channelId string, userId *string) error {
+	receivedAt := time.Now().UnixMilli()
+
 	type Message struct {
 		Type    string `json:"type"`
 		Content string `json:"content"`
@@ -135,31 +242,78 @@ func (h *Handler) handleMessage(messageType int, message []byte, channelId strin
 	var messageContent []byte
 	var err error
 
+	// clock synchronization doesn't require authorization
+	if m.Type == MessageTypePing {
+		return handlePingMessage(conn, messageType, m.Content, receivedAt)
+	}
+
 	if *userId == "" && m.Type != MessageTypeAuth {
 		return errors.New("user not authorized")
 	}
 
 	switch m.Type {
 	case MessageTypeAuth:
-		return h.handleAuthMessage(m.Content, userId)
+		if err = h.handleAuthMessage(m.Content, userId); err != nil {
+			return err
+		}
+		SetConnectionUser(conn, *userId)
+		return nil
 	case MessageTypeChangeTime:
-		if err = h.handleTimeMessage(m.Content, channelId); err != nil {
+		messageContent, err = h.handleTimeMessage(m.Content, channelId, *userId)
+		if err != nil {
 			return err
 		}
-		messageContent = message
 	case MessageTypeText:
 		messageContent, err = h.processMessage(m.Content, channelId, userId)
 		if err != nil {
 			return err
 		}
+	case MessageTypeSkip:
+		messageContent, err = h.handleSkipMessage(channelId, *userId)
+		if err != nil {
+			return err
+		}
+	case MessageTypeRemove, MessageTypeMove, MessageTypePlayNext:
+		messageContent, err = h.handleQueueMessage(m.Type, m.Content, channelId, *userId)
+		if err != nil {
+			return err
+		}
+	case MessageTypeApprove, MessageTypeReject:
+		messageContent, err = h.handleRequestDecisionMessage(m.Type, m.Content, channelId, *userId)
+		if err != nil {
+			return err
+		}
+	case MessageTypeLike:
+		messageContent, err = h.handleLikeMessage(m.Content, channelId, *userId)
+		if err != nil {
+			return err
+		}
+	case MessageTypePause, MessageTypeResume:
+		messageContent, err = h.handlePlaybackMessage(m.Type, channelId, *userId)
+		if err != nil {
+			return err
+		}
+	}
+
+	// nothing to broadcast, e.g. the response was sent only to some users
+	if messageContent == nil {
+		return nil
 	}
 
 	return broadcastMessage(messageType, messageContent, channelId)
 }
 
 func (h *Handler) processMessage(message, channelId string, userId *string) ([]byte, error) {
-	if songId := helper.MatchSongUrl(string(message)); songId != "" {
-		return h.handleSongMessage(songId, channelId, userId)
+	if query, found := strings.CutPrefix(message, PlayCommand); found {
+		return h.handlePlayCommand(query, channelId, userId)
+	}
+
+	if provider, playlistId := h.Providers.MatchPlaylist(message); provider != nil {
+		return h.handlePlaylistMessage(provider, playlistId, channelId, userId)
+	}
+
+	if provider, mediaId := h.Providers.Match(message); provider != nil {
+		return h.handleSongMessage(provider, mediaId, message, channelId, userId)
 	}
 
 	return h.handleTextMessage(message, userId, channelId)
@@ -181,37 +335,154 @@ func authorizeUser(authToken string, userId *string) error {
 	return nil
 }
 
-func (h *Handler) handleSongMessage(songId, channelId string, userId *string) ([]byte, error) {
-	data, err := getSongData(songId)
+func (h *Handler) handleSongMessage(provider media.MediaProvider, mediaId, url, channelId string, userId *string) ([]byte, error) {
+	songData, err := provider.FetchSong(mediaId)
+	if err != nil {
+		log.Println(err)
+		return nil, providerRejection(err)
+	}
+
+	songData.SetOffsets(helper.MatchSongOffsets(url))
+
+	return h.enqueueSong(songData, channelId, userId)
+}
+
+// handlePlaylistMessage enqueues songs of the playlist in order and returns one message about all of them
+func (h *Handler) handlePlaylistMessage(provider media.PlaylistProvider, playlistId, channelId string, userId *string) ([]byte, error) {
+	songs, err := provider.FetchPlaylist(playlistId)
+	if err != nil {
+		log.Println(err)
+		return nil, providerRejection(err)
+	}
+
+	channel, err := h.fetchChannel(context.Background(), channelId)
 	if err != nil {
 		return nil, err
 	}
 
-	songData, err := models.BuildSongFromYoutubeData(data)
+	moderated := channel.Settings.ModeratedRequests && !channel.IsCurator(*userId)
+
+	allowed := make([]models.Song, 0, len(songs))
+	for _, song := range songs {
+		song = song.NewEntry()
+		song.AddedBy = *userId
+		allowed = append(allowed, song)
+	}
+
+	var skipped []SongRejection
+	if moderated {
+		allowed, skipped = h.filterRequests(channel, allowed)
+	}
+
+	// when nothing is left to add, the sender learns why from the skipped songs
+	var scheduled []models.Song
+	switch {
+	case len(allowed) == 0:
+	case moderated:
+		err = h.SubmitRequests(channel, allowed)
+	default:
+		var rejected []SongRejection
+		scheduled, rejected, err = h.PlaySongs(allowed, channelId)
+		skipped = append(skipped, rejected...)
+	}
+
+	if err := notifySkippedSongs(channelId, *userId, skipped); err != nil {
+		log.Println(err)
+	}
+
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		// every song was rejected and is listed in the skipped ones
+		return nil, nil
+	} else if err != nil || len(scheduled) == 0 {
+		return nil, err
+	}
+
+	if channel.Settings.FairQueue {
+		if err := h.broadcastQueue(channelId); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return h.announceSongs(scheduled, channelId, *userId)
+}
+
+// handlePlayCommand enqueues the top search result of the query
+func (h *Handler) handlePlayCommand(query, channelId string, userId *string) ([]byte, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("empty search query")
+	}
+
+	provider, _ := h.Providers.Get(media.ProviderYouTube)
+	if provider == nil {
+		return nil, media.ErrSearchUnsupported
+	}
+
+	songs, err := h.Search.Search(provider, query)
+	if err != nil {
+		log.Println(err)
+		return nil, providerRejection(err)
+	}
+
+	if len(songs) == 0 {
+		return nil, &Rejection{
+			Reason:  RejectionSongNotFound,
+			Message: "no songs found",
+		}
+	}
+
+	return h.enqueueSong(songs[0], channelId, userId)
+}
+
+// enqueueSong adds the song posted by the user to the queue, or submits it for approval
+// when the channel moderates requests
+func (h *Handler) enqueueSong(songData models.Song, channelId string, userId *string) ([]byte, error) {
+	channel, err := h.fetchChannel(context.Background(), channelId)
 	if err != nil {
 		return nil, err
 	}
 
 	newSongId := primitive.NewObjectID()
 	songData.Id = newSongId
+	songData.AddedBy = *userId
+
+	if channel.Settings.ModeratedRequests && !channel.IsCurator(*userId) {
+		allowed, skipped := h.filterRequests(channel, []models.Song{songData})
+		if len(skipped) > 0 {
+			return nil, skipped[0].Rejection
+		}
+		return nil, h.SubmitRequest(channel, allowed[0])
+	}
 
 	songData, err = h.PlaySong(songData, channelId)
 	if err != nil {
 		return nil, err
 	}
 
+	// other songs could have been moved to make room for the new one
+	if channel.Settings.FairQueue {
+		if err := h.broadcastQueue(channelId); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return h.announceSong(songData, channelId, *userId)
+}
+
+// announceSong saves message about the song added to the queue and returns it
+func (h *Handler) announceSong(song models.Song, channelId, author string) ([]byte, error) {
 	response := map[string]interface{}{
-		"author":  *userId,
-		"content": songData,
+		"author":  author,
+		"content": song,
 		"type":    "song",
-		"id":      newSongId,
+		"id":      song.Id,
 	}
 
 	messageToSave := models.Message{
-		Author:  *userId,
+		Author:  author,
 		Type:    "song",
-		Id:      newSongId,
-		SongRef: newSongId,
+		Id:      song.Id,
+		SongRef: song.Id,
 	}
 
 	if err := h.saveMessage(messageToSave, channelId); err != nil {
@@ -244,7 +515,10 @@ func (h *Handler) saveMessage(message models.Message, channelId string) error {
 		return err
 	}
 
-	filter := bson.M{"_id": channelObjectId}
+	filter := bson.M{
+		"_id":        channelObjectId,
+		"deleted_at": bson.M{"$exists": false},
+	}
 	update := bson.M{"$push": bson.M{"messages": message}}
 
 	_, err = collection.UpdateOne(context.Background(), filter, update)
@@ -256,6 +530,38 @@ func (h *Handler) saveMessage(message models.Message, channelId string) error {
 
 }
 
+func (h *Handler) saveMessages(messages []models.Message, channelId string) error {
+	channelObjectId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id":        channelObjectId,
+		"deleted_at": bson.M{"$exists": false},
+	}
+	update := bson.M{"$push": bson.M{"messages": bson.M{"$each": messages}}}
+
+	_, err = h.Db.Collection("channel").UpdateOne(context.Background(), filter, update)
+
+	return err
+}
+
+// sendToUsers sends the message to connections of the channel which belong to users matching the predicate
+func sendToUsers(messageType int, message []byte, channelId string, match func(userId string) bool) error {
+	clientRegistry.m.Lock()
+	defer clientRegistry.m.Unlock()
+	for _, conn := range clientRegistry.conns[channelId] {
+		if userId := clientRegistry.users[conn]; userId != "" && match(userId) {
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func broadcastMessage(messageType int, message []byte, channelId string) error {
 	clientRegistry.m.Lock()
 	defer clientRegistry.m.Unlock()
@@ -269,72 +575,150 @@ func broadcastMessage(messageType int, message []byte, channelId string) error {
 }
 
 func (h *Handler) PlaySong(song models.Song, channelId string) (models.Song, error) {
+	songs, _, err := h.PlaySongs([]models.Song{song}, channelId)
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	return songs[0], nil
+}
+
+// PlaySongs adds songs to the queue in one update and returns the scheduled ones. Songs rejected by the channel's
+// rules are left out and returned with the reasons, the first rejection is returned as error if none could be added.
+func (h *Handler) PlaySongs(songs []models.Song, channelId string) ([]models.Song, []SongRejection, error) {
 	// Add to queue
 
-	var queue models.Queue
+	if _, err := primitive.ObjectIDFromHex(channelId); err != nil {
+		return nil, nil, fmt.Errorf("invalid id: %s", channelId)
+	}
 
-	collection := h.Db.Collection("queue")
+	if len(songs) == 0 {
+		return nil, nil, errors.New("no songs to play")
+	}
 
-	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	channel, err := h.fetchChannel(context.Background(), channelId)
 	if err != nil {
-		return models.Song{}, fmt.Errorf("invalid id: %s", channelId)
+		return nil, nil, err
 	}
 
-	filter := bson.M{"channel_id": channelObjId}
+	songs = h.resolveUploaders(channel, songs)
 
-	err = collection.FindOne(context.Background(), filter).Decode(&queue)
+	var scheduled []models.Song
+	var rejected []SongRejection
 
-	if queue.ChannelId == primitive.NilObjectID {
-		queue, err = h.insertNewQueue(channelId)
-		if err != nil || queue.Id.Hex() == "" {
-			return models.Song{}, fmt.Errorf("couldn't insert queue (id: %s), error: %s", queue.Id, err)
+	schedule := func(queue *models.Queue) error {
+		added := make(map[primitive.ObjectID]struct{}, len(songs))
+		rejected = nil
+
+		for _, song := range songs {
+			if err := h.scheduleSong(channel, queue, song); err != nil {
+				var rejection *Rejection
+				if !errors.As(err, &rejection) {
+					return err
+				}
+
+				rejected = append(rejected, SongRejection{Song: song, Rejection: rejection})
+				continue
+			}
+
+			added[song.Id] = struct{}{}
 		}
+
+		if len(added) == 0 {
+			return rejected[0].Rejection
+		}
+
+		// songs are taken from the queue at the end, in fair queue mode
+		// their start times could change when scheduling the following ones
+		scheduled = make([]models.Song, 0, len(added))
+		for _, song := range queue.Songs {
+			if _, exists := added[song.Id]; exists {
+				scheduled = append(scheduled, song)
+			}
+		}
+
+		return nil
+	}
+
+	_, err = h.updateQueue(channelId, schedule)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := h.insertNewQueue(channelId); err != nil {
+			return nil, nil, fmt.Errorf("couldn't insert queue (channel id: %s), error: %s", channelId, err)
+		}
+
+		_, err = h.updateQueue(channelId, schedule)
+	}
+
+	if err != nil {
+		return nil, rejected, err
+	}
+
+	return scheduled, rejected, nil
+}
+
+// scheduleSong checks the song against the channel's rules and adds it to the queue
+func (h *Handler) scheduleSong(channel models.Channel, queue *models.Queue, song models.Song) error {
+	if err := checkBlocklist(channel, song); err != nil {
+		return err
 	}
 
-	if len(queue.Songs) == 0 {
-		song.SongStartTime = time.Now().UnixMilli()
+	if err := checkRestrictions(channel, song); err != nil {
+		return err
+	}
+
+	if err := checkQueuePolicy(channel, *queue, song); err != nil {
+		return err
+	}
+
+	if err := h.checkReplay(*queue, song, channel.Settings.ReplayCooldown); err != nil {
+		return err
+	}
+
+	now := queue.Now()
+
+	if channel.Settings.FairQueue {
+		queue.InsertFair(song, now)
+	} else if len(queue.Songs) == 0 {
+		song.SongStartTime = now
 		queue.Songs = []models.Song{song}
 	} else {
 		lastSong := queue.Songs[len(queue.Songs)-1]
 
-		if lastSong.SongStartTime+int64(lastSong.Duration*1000) <= time.Now().UnixMilli() {
-			song.SongStartTime = time.Now().UnixMilli()
+		if lastSong.EndTime() <= now {
+			song.SongStartTime = now
 		} else {
-			song.SongStartTime = lastSong.SongStartTime + int64(lastSong.Duration*1000)
+			song.SongStartTime = lastSong.EndTime()
 		}
 
 		queue.Songs = append(queue.Songs, song)
 	}
 
-	if _, err := collection.ReplaceOne(context.Background(), filter, queue); err != nil {
-		return models.Song{}, err
-	}
-
-	return song, nil
+	return nil
 }
 
-func (h *Handler) insertNewQueue(channelId string) (models.Queue, error) {
+// insertNewQueue creates queue for the channel unless it already exists
+func (h *Handler) insertNewQueue(channelId string) error {
 	collection := h.Db.Collection("queue")
 
 	channelObjId, err := primitive.ObjectIDFromHex(channelId)
 	if err != nil {
-		return models.Queue{}, err
-	}
-
-	queue := models.Queue{
-		ChannelId: channelObjId,
-		Songs:     []models.Song{},
+		return err
 	}
 
-	res, err := collection.InsertOne(context.Background(), queue)
-	if err != nil {
-		return models.Queue{}, err
-	}
+	filter := bson.M{"channel_id": channelObjId}
+	update := bson.M{"$setOnInsert": bson.M{
+		"songs":   []models.Song{},
+		"version": 0,
+	}}
 
-	queue.Id = res.InsertedID.(primitive.ObjectID)
+	_, err = collection.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
 
-	return queue, nil
+	// queue was inserted by another request in the meantime
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
 
+	return err
 }
 
 func (h *Handler) AddToFollowedChannels(userId, channelId string) error {
@@ -383,6 +767,7 @@ func (h *Handler) CreateChannel(c *gin.Context) {
 		{Key: "name", Value: channel.Name},
 		{Key: "owner", Value: userId},
 		{Key: "description", Value: channel.Description},
+		{Key: "settings", Value: channel.Settings},
 	}
 
 	res, err := collection.InsertOne(context.TODO(), channelBson)
@@ -437,24 +822,40 @@ func (h *Handler) FetchChannelsWithLastPlayedSong(c *gin.Context, channelID ...s
 	currentTimestamp := time.Now().UnixMilli()
 
 	pipeline := []bson.M{
-		{"$match": bson.M{"_id": bson.M{"$in": channelObjIdList}}},
+		{"$match": bson.M{
+			"_id":        bson.M{"$in": channelObjIdList},
+			"deleted_at": bson.M{"$exists": false},
+		}},
 		{"$lookup": bson.M{
 			"from": "queue",
 			"let":  bson.M{"channel_id": "$_id"},
 			"pipeline": []bson.M{
 				{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$channel_id", "$$channel_id"}}}},
+				{"$set": bson.M{"now": models.QueueClockExpr("$playback", currentTimestamp)}},
 				{"$unwind": "$songs"},
-				{"$match": bson.M{"songs.song_start_time": bson.M{"$lt": currentTimestamp}}},
+				{"$match": bson.M{"$expr": bson.M{"$lt": []interface{}{"$songs.song_start_time", "$now"}}}},
 				{"$sort": bson.M{"songs.song_start_time": -1}},
 				{"$limit": 1},
 			},
 			"as": "lastPlayedSong",
 		}},
+		{"$lookup": bson.M{
+			"from": "song_history",
+			"let":  bson.M{"channel_id": "$_id"},
+			"pipeline": []bson.M{
+				{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$channel_id", "$$channel_id"}}}},
+				{"$sort": bson.M{"song_start_time": -1}},
+				{"$limit": 1},
+			},
+			"as": "lastArchivedSong",
+		}},
 		{"$unwind": bson.M{"path": "$messages", "preserveNullAndEmptyArrays": true}},
+		// lookups match channel_id first, so only the channel's queue and history are searched
 		{"$lookup": bson.M{
 			"from": "queue",
-			"let":  bson.M{"song_id": "$messages.song"},
+			"let":  bson.M{"channel_id": "$_id", "song_id": "$messages.song"},
 			"pipeline": []bson.M{
+				{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$channel_id", "$$channel_id"}}}},
 				{"$unwind": "$songs"},
 				{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$songs.id", "$$song_id"}}}},
 				{"$replaceRoot": bson.M{"newRoot": "$songs"}},
@@ -462,21 +863,46 @@ func (h *Handler) FetchChannelsWithLastPlayedSong(c *gin.Context, channelID ...s
 			"as": "messages.songDetails",
 		},
 		},
+		{"$lookup": bson.M{
+			"from": "song_history",
+			"let":  bson.M{"channel_id": "$_id", "song_id": "$messages.song"},
+			"pipeline": []bson.M{
+				{"$match": bson.M{"$expr": bson.M{"$and": []interface{}{
+					bson.M{"$eq": []interface{}{"$channel_id", "$$channel_id"}},
+					bson.M{"$eq": []interface{}{"$id", "$$song_id"}},
+				}}}},
+			},
+			"as": "messages.archivedSongDetails",
+		}},
+		{"$set": bson.M{
+			"messages.songDetails": bson.M{"$concatArrays": []interface{}{"$messages.songDetails", "$messages.archivedSongDetails"}},
+		}},
+		{"$unset": "messages.archivedSongDetails"},
 		{"$group": bson.M{
-			"_id":            "$_id",
-			"lastPlayedSong": bson.M{"$first": "$lastPlayedSong"},
-			"name":           bson.M{"$first": "$name"},
-			"owner":          bson.M{"$first": "$owner"},
-			"description":    bson.M{"$first": "$description"},
-			"messages":       bson.M{"$push": "$messages"},
+			"_id":              "$_id",
+			"lastPlayedSong":   bson.M{"$first": "$lastPlayedSong"},
+			"lastArchivedSong": bson.M{"$first": "$lastArchivedSong"},
+			"name":             bson.M{"$first": "$name"},
+			"owner":            bson.M{"$first": "$owner"},
+			"description":      bson.M{"$first": "$description"},
+			"moderators":       bson.M{"$first": "$moderators"},
+			"djs":              bson.M{"$first": "$djs"},
+			"settings":         bson.M{"$first": "$settings"},
+			"messages":         bson.M{"$push": "$messages"},
 		},
 		},
 		{"$project": bson.M{
-			"messages":       1,
-			"name":           1,
-			"owner":          1,
-			"description":    1,
-			"lastPlayedSong": bson.M{"$arrayElemAt": []interface{}{"$lastPlayedSong.songs", 0}},
+			"messages":    1,
+			"name":        1,
+			"owner":       1,
+			"description": 1,
+			"moderators":  1,
+			"djs":         1,
+			"settings":    1,
+			"lastPlayedSong": bson.M{"$ifNull": []interface{}{
+				bson.M{"$arrayElemAt": []interface{}{"$lastPlayedSong.songs", 0}},
+				bson.M{"$arrayElemAt": []interface{}{"$lastArchivedSong", 0}},
+			}},
 		}},
 	}
 
@@ -499,7 +925,7 @@ func (h *Handler) FetchChannelsWithLastPlayedSong(c *gin.Context, channelID ...s
 
 }
 
-func (h *Handler) FetchUpcomingSongsForChannel(c *gin.Context, channelID string) (bson.M, error) {
+func (h *Handler) FetchUpcomingSongsForChannel(c context.Context, channelID string) (bson.M, error) {
 	channelObjId, err := primitive.ObjectIDFromHex(channelID)
 	if err != nil {
 		return nil, err
@@ -510,11 +936,12 @@ func (h *Handler) FetchUpcomingSongsForChannel(c *gin.Context, channelID string)
 	currentTimestamp := time.Now().UnixMilli()
 	pipeline := []bson.M{
 		{"$match": bson.M{"channel_id": channelObjId}},
+		{"$set": bson.M{"now": models.QueueClockExpr("$playback", currentTimestamp)}},
 		{
 			"$unwind": "$songs",
 		},
 		{
-			"$match": bson.M{"songs.song_start_time": bson.M{"$gt": currentTimestamp}},
+			"$match": bson.M{"$expr": bson.M{"$gt": []interface{}{"$songs.song_start_time", "$now"}}},
 		},
 		{
 			"$group": bson.M{
@@ -564,47 +991,70 @@ func (h *Handler) GetChannel(c *gin.Context) {
 		return
 	}
 
+	playback, err := h.fetchPlaybackStatus(channelID)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"channel": channel,
-		"queue":   queue,
+		"channel":  channel,
+		"queue":    queue,
+		"playback": playback,
 	})
 }
 
-func getSongData(url string) (models.YoutubeVideoData, error) {
-
-	var data models.YoutubeVideoData
-	apiKey := helper.GetEnv("YOUTUBE_API_KEY", "")
-	if apiKey == "" {
-		return data, errors.New("no API key")
+// SearchSongs returns songs matching "q" query parameter, provider is selected with "provider" query parameter
+func (h *Handler) SearchSongs(c *gin.Context) {
+	query := c.Query("q")
+	if strings.TrimSpace(query) == "" {
+		c.Status(http.StatusBadRequest)
+		return
 	}
 
-	requestUrl := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?id=%s&key=%s&part=snippet,contentDetails", url, apiKey)
-	res, err := http.Get(requestUrl)
-	if err != nil {
-		log.Println(err)
-		return data, err
+	provider, exists := h.Providers.Get(c.DefaultQuery("provider", media.ProviderYouTube))
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
 	}
 
-	body, err := io.ReadAll(res.Body)
+	songs, err := h.Search.Search(provider, query)
 	if err != nil {
 		log.Println(err)
+		if errors.Is(err, media.ErrSearchUnsupported) {
+			c.Status(http.StatusBadRequest)
+		} else {
+			c.Status(http.StatusServiceUnavailable)
+		}
+		return
 	}
 
-	json.Unmarshal(body, &data)
-
-	return data, nil
-
+	c.JSON(http.StatusOK, songs)
 }
 
+// GetSongData returns the song with the given id, provider is selected with "provider" query parameter
 func (h *Handler) GetSongData(c *gin.Context) {
 	id := c.Param("id")
 
-	videoData, err := getSongData(id)
-	if err != nil {
+	provider, exists := h.Providers.Get(c.DefaultQuery("provider", media.ProviderYouTube))
+	if !exists {
 		c.Status(http.StatusNotFound)
+		return
+	}
+
+	song, err := provider.FetchSong(id)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, media.ErrNotFound) {
+			c.Status(http.StatusNotFound)
+		} else {
+			c.Status(http.StatusServiceUnavailable)
+		}
+		return
 	}
 
-	c.JSON(http.StatusOK, videoData)
+	c.JSON(http.StatusOK, song)
 }
 
 func (h *Handler) FollowChannel(c *gin.Context) {
@@ -618,7 +1068,10 @@ func (h *Handler) FollowChannel(c *gin.Context) {
 	claims := auth.ExtractClaimsFromContext(c)
 	userId := claims.Id
 
-	filter := bson.M{"_id": channelObjId}
+	filter := bson.M{
+		"_id":        channelObjId,
+		"deleted_at": bson.M{"$exists": false},
+	}
 
 	opts := options.FindOne().SetProjection(
 		bson.M{
@@ -652,6 +1105,43 @@ func (h *Handler) FollowChannel(c *gin.Context) {
 	}
 }
 
+func (h *Handler) fetchChannel(c context.Context, channelId string) (models.Channel, error) {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return models.Channel{}, err
+	}
+
+	filter := bson.M{
+		"_id":        channelObjId,
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"messages": 0})
+
+	var channel models.Channel
+	if err := h.Db.Collection("channel").FindOne(c, filter, opts).Decode(&channel); err != nil {
+		return models.Channel{}, err
+	}
+
+	return channel, nil
+}
+
+func (h *Handler) channelExists(c context.Context, channelId string) bool {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return false
+	}
+
+	exists, err := db.ChannelExists(c, h.Db, channelObjId)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return exists
+}
+
+// DeleteChannel only marks the channel as deleted, it can be restored with RestoreChannel
+// until RestoreWindow passes and PurgeDeletedChannels removes it for good
 func (h *Handler) DeleteChannel(c *gin.Context) {
 	channelId := c.Param("id")
 
@@ -665,43 +1155,120 @@ func (h *Handler) DeleteChannel(c *gin.Context) {
 
 	collection := h.Db.Collection("channel")
 	filter := bson.M{
-		"_id":   channelObjId,
-		"owner": claims.Id,
+		"_id":        channelObjId,
+		"owner":      claims.Id,
+		"deleted_at": bson.M{"$exists": false},
 	}
+	update := bson.M{"$set": bson.M{"deleted_at": time.Now().UnixMilli()}}
 
-	if res, err := collection.DeleteOne(c, filter); err != nil {
+	if res, err := collection.UpdateOne(c, filter, update); err != nil {
 		log.Println(err)
 		c.Status(http.StatusInternalServerError)
 		return
-	} else if res.DeletedCount == 0 {
+	} else if res.MatchedCount == 0 {
 		c.Status(http.StatusForbidden)
 		return
 	}
 
-	collection = h.Db.Collection("queue")
-	filter = bson.M{
-		"channel_id": channelObjId,
+	CloseConnections(channelId)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) RestoreChannel(c *gin.Context) {
+	channelId := c.Param("id")
+
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
 	}
 
-	if res, err := collection.DeleteOne(c, filter); err != nil {
+	claims := auth.ExtractClaimsFromContext(c)
+
+	collection := h.Db.Collection("channel")
+	filter := bson.M{
+		"_id":        channelObjId,
+		"owner":      claims.Id,
+		"deleted_at": bson.M{"$gt": time.Now().Add(-h.RestoreWindow).UnixMilli()},
+	}
+	update := bson.M{"$unset": bson.M{"deleted_at": ""}}
+
+	if res, err := collection.UpdateOne(c, filter, update); err != nil {
 		log.Println(err)
-	} else if res.DeletedCount == 0 {
-		log.Printf("Couldn't delete queue for channel %s\n", channelId)
+		c.Status(http.StatusInternalServerError)
+		return
+	} else if res.MatchedCount == 0 {
+		c.Status(http.StatusNotFound)
+		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-func (h *Handler) ChangeTime(time float64, channelObjId primitive.ObjectID) error {
-	collection := h.Db.Collection("queue")
+// PurgeDeletedChannels removes channels (with their queues, history, likes and requests) which were deleted
+// more than RestoreWindow ago
+func (h *Handler) PurgeDeletedChannels(ctx context.Context) error {
+	collection := h.Db.Collection("channel")
 
-	filter := bson.M{"channel_id": channelObjId}
+	filter := bson.M{"deleted_at": bson.M{"$lte": time.Now().Add(-h.RestoreWindow).UnixMilli()}}
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+
+	var channels []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &channels); err != nil {
+		return err
+	}
+
+	if len(channels) == 0 {
+		return nil
+	}
+
+	channelObjIdList := make([]primitive.ObjectID, 0, len(channels))
+	for _, channel := range channels {
+		channelObjIdList = append(channelObjIdList, channel.Id)
+	}
+
+	for _, collectionName := range []string{"queue", "song_history", "song_like", "song_request"} {
+		if _, err := h.Db.Collection(collectionName).DeleteMany(ctx, bson.M{"channel_id": bson.M{"$in": channelObjIdList}}); err != nil {
+			return err
+		}
+	}
 
-	update := bson.M{"$inc": bson.M{"songs.$[].song_start_time": -time * 1000}}
+	update := bson.M{"$pull": bson.M{"followed_channels": bson.M{"$in": channelObjIdList}}}
+	if _, err := h.Db.Collection("user").UpdateMany(ctx, bson.M{"followed_channels": bson.M{"$in": channelObjIdList}}, update); err != nil {
+		return err
+	}
 
-	if _, err := collection.UpdateOne(context.TODO(), filter, update); err != nil {
+	if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": channelObjIdList}}); err != nil {
 		return err
 	}
 
+	log.Printf("Purged %d deleted channels\n", len(channelObjIdList))
+
 	return nil
 }
+
+func (h *Handler) StartPurgeWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.PurgeDeletedChannels(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// SeekTo sets position (ms) within the currently playing song, moving it and the upcoming songs
+func (h *Handler) SeekTo(channelId string, position int64) (models.Queue, error) {
+	return h.updateQueue(channelId, func(queue *models.Queue) error {
+		return queue.SeekTo(position, queue.Now())
+	})
+}
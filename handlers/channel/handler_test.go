@@ -0,0 +1,62 @@
+package channel
+
+import (
+	"context"
+	"nbeat-api/db"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestHandler returns handler using a fresh database on the server from TEST_MONGO_URI,
+// tests which need the database are skipped when it's not set
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := client.Database("nbeat_test_" + primitive.NewObjectID().Hex())
+
+	t.Cleanup(func() {
+		database.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+
+	if err := db.CreateIndexes(database); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Handler{Db: database}
+}
+
+// insertTestChannel creates channel owned by the user and returns its id
+func insertTestChannel(t *testing.T, h *Handler, owner string, settings bson.M) string {
+	t.Helper()
+
+	res, err := h.Db.Collection("channel").InsertOne(context.Background(), bson.M{
+		"name":     "test",
+		"owner":    owner,
+		"settings": settings,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.InsertedID.(primitive.ObjectID).Hex()
+}
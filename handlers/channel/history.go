@@ -0,0 +1,226 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"nbeat-api/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// archivePlayedSongs moves songs which finished playing from the saved queue to song_history.
+// Songs are removed from the queue only after they're archived, so they can always be found in one of them.
+func (h *Handler) archivePlayedSongs(queue *models.Queue) error {
+	played := queue.DropPlayedSongs(queue.Now())
+	if len(played) == 0 {
+		return nil
+	}
+
+	// upserts keep archiving idempotent in case removing songs from the queue fails afterwards
+	writes := make([]mongo.WriteModel, 0, len(played))
+	for _, song := range played {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"channel_id": queue.ChannelId, "id": song.Id}).
+			SetReplacement(models.HistorySong{ChannelId: queue.ChannelId, Song: song}).
+			SetUpsert(true),
+		)
+	}
+
+	res, err := h.Db.Collection("song_history").BulkWrite(context.Background(), writes)
+	if err != nil {
+		return err
+	}
+
+	// songs archived by an earlier attempt were already counted
+	if h.Catalog != nil && len(res.UpsertedIDs) > 0 {
+		counted := make([]models.Song, 0, len(res.UpsertedIDs))
+		for idx := range res.UpsertedIDs {
+			counted = append(counted, played[idx])
+		}
+
+		if err := h.Catalog.CountPlays(counted); err != nil {
+			log.Println(err)
+		}
+	}
+
+	// songs rescheduled in the meantime (e.g. by seeking) don't match and are archived again later
+	archived := make([]bson.M, 0, len(played))
+	for _, song := range played {
+		archived = append(archived, bson.M{"id": song.Id, "song_start_time": song.SongStartTime})
+	}
+
+	filter := bson.M{"_id": queue.Id, "songs": bson.M{"$elemMatch": bson.M{"$or": archived}}}
+	update := bson.M{
+		"$pull": bson.M{"songs": bson.M{"$or": archived}},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	_, err = h.Db.Collection("queue").UpdateOne(context.Background(), filter, update)
+
+	return err
+}
+
+// StartArchiveWorker periodically archives played songs of all queues,
+// so history doesn't wait for the next change of the queue in idle channels
+func (h *Handler) StartArchiveWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.archiveQueues(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (h *Handler) archiveQueues() error {
+	// songs are ordered by start time, queues with the first song already started may have played songs
+	filter := bson.M{"songs.0.song_start_time": bson.M{"$lt": time.Now().UnixMilli()}}
+	opts := options.Find().SetProjection(bson.M{"channel_id": 1})
+
+	cursor, err := h.Db.Collection("queue").Find(context.Background(), filter, opts)
+	if err != nil {
+		return err
+	}
+
+	var queues []models.Queue
+	if err := cursor.All(context.Background(), &queues); err != nil {
+		return err
+	}
+
+	for _, queue := range queues {
+		if err := h.archiveQueue(queue.ChannelId.Hex()); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return nil
+}
+
+// archiveQueue moves played songs of the channel's queue to song_history
+func (h *Handler) archiveQueue(channelId string) error {
+	queue, err := h.fetchQueue(channelId)
+	if err != nil {
+		return err
+	}
+
+	return h.archivePlayedSongs(&queue)
+}
+
+// findRecentlyPlayed returns the latest archived entry of the song which started after since
+func (h *Handler) findRecentlyPlayed(channelObjId primitive.ObjectID, songId string, since int64) (models.Song, bool, error) {
+	filter := bson.M{
+		"channel_id":      channelObjId,
+		"song_id":         songId,
+		"song_start_time": bson.M{"$gte": since},
+	}
+	opts := options.FindOne().SetSort(bson.M{"song_start_time": -1})
+
+	var entry models.HistorySong
+	err := h.Db.Collection("song_history").FindOne(context.Background(), filter, opts).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Song{}, false, nil
+	} else if err != nil {
+		return models.Song{}, false, err
+	}
+
+	return entry.Song, true, nil
+}
+
+// historyCursor points after the given entry, entries of songs started at the same time are ordered by id
+func historyCursor(song models.Song) string {
+	return fmt.Sprintf("%d_%s", song.SongStartTime, song.Id.Hex())
+}
+
+// parseHistoryCursor returns filter matching entries placed after the cursor
+func parseHistoryCursor(cursor string) (bson.M, error) {
+	timestamp, id, found := strings.Cut(cursor, "_")
+	if !found {
+		return nil, errors.New("invalid history cursor")
+	}
+
+	startTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	songObjId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"$or": []bson.M{
+		{"song_start_time": bson.M{"$lt": startTime}},
+		{"song_start_time": startTime, "id": bson.M{"$lt": songObjId}},
+	}}, nil
+}
+
+// GetHistory returns songs played in the channel, newest first.
+// Next page can be fetched by passing returned "next" value as "before" query parameter.
+func (h *Handler) GetHistory(c *gin.Context) {
+	channelId := c.Param("id")
+
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil || !h.channelExists(c, channelId) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultHistoryLimit)))
+	if err != nil || limit <= 0 || limit > maxHistoryLimit {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"channel_id": channelObjId}
+
+	if before := c.Query("before"); before != "" {
+		after, err := parseHistoryCursor(before)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		filter = bson.M{"$and": []bson.M{filter, after}}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "song_start_time", Value: -1}, {Key: "id", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := h.Db.Collection("song_history").Find(c, filter, opts)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	songs := []models.HistorySong{}
+	if err := cursor.All(c, &songs); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	response := gin.H{"songs": songs}
+	if len(songs) == limit {
+		response["next"] = historyCursor(songs[len(songs)-1].Song)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
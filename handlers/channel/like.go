@@ -0,0 +1,301 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"nbeat-api/middleware/auth"
+	"nbeat-api/models"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const leaderboardLimit = 10
+
+// Time windows of leaderboards, zero means all time
+var leaderboardWindows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+// findChannelSong returns entry with the given id from the channel's queue or history
+func (h *Handler) findChannelSong(channelId string, songId primitive.ObjectID) (models.Song, error) {
+	queue, err := h.fetchQueue(channelId)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Song{}, err
+	}
+
+	// songs which haven't started yet can't be liked
+	now := queue.Now()
+	if idx := slices.IndexFunc(queue.Songs, func(s models.Song) bool { return s.Id == songId && s.SongStartTime <= now }); idx != -1 {
+		return queue.Songs[idx], nil
+	}
+
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	var entry models.HistorySong
+	filter := bson.M{"channel_id": channelObjId, "id": songId}
+	if err := h.Db.Collection("song_history").FindOne(context.Background(), filter).Decode(&entry); err != nil {
+		return models.Song{}, err
+	}
+
+	return entry.Song, nil
+}
+
+// LikeSong saves like of the song from the channel's queue or history and returns number of its likes
+func (h *Handler) LikeSong(channelId, userId string, songId primitive.ObjectID) (int64, error) {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return 0, err
+	}
+
+	song, err := h.findChannelSong(channelId, songId)
+	if err != nil {
+		return 0, err
+	}
+
+	like := models.Like{
+		ChannelId: channelObjId,
+		UserId:    userId,
+		CreatedAt: time.Now().UnixMilli(),
+		Song:      song,
+	}
+
+	collection := h.Db.Collection("song_like")
+
+	// liking the same song again is not an error
+	if _, err := collection.InsertOne(context.Background(), like); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return 0, err
+	}
+
+	return collection.CountDocuments(context.Background(), bson.M{"song.id": songId})
+}
+
+func (h *Handler) UnlikeSong(channelId, userId string, songId primitive.ObjectID) (int64, error) {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return 0, err
+	}
+
+	collection := h.Db.Collection("song_like")
+
+	filter := bson.M{"channel_id": channelObjId, "song.id": songId, "user": userId}
+	if _, err := collection.DeleteOne(context.Background(), filter); err != nil {
+		return 0, err
+	}
+
+	return collection.CountDocuments(context.Background(), bson.M{"song.id": songId})
+}
+
+func likeMessage(songId primitive.ObjectID, likes int64) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type": "like",
+		"content": map[string]interface{}{
+			"song":  songId,
+			"likes": likes,
+		},
+	})
+}
+
+// handleLikeMessage likes the song with id from the message, or the current one if it's empty
+func (h *Handler) handleLikeMessage(content, channelId, userId string) ([]byte, error) {
+	var songId primitive.ObjectID
+
+	if content != "" {
+		var err error
+		if songId, err = primitive.ObjectIDFromHex(content); err != nil {
+			return nil, err
+		}
+	} else {
+		queue, err := h.fetchQueue(channelId)
+		if err != nil {
+			return nil, err
+		}
+
+		status := queue.Status()
+		if status.Song == nil {
+			return nil, models.ErrNothingPlaying
+		}
+
+		songId = status.Song.Id
+	}
+
+	likes, err := h.LikeSong(channelId, userId, songId)
+	if err != nil {
+		return nil, err
+	}
+
+	return likeMessage(songId, likes)
+}
+
+func (h *Handler) respondToLike(c *gin.Context, like func(channelId, userId string, songId primitive.ObjectID) (int64, error)) {
+	channelId := c.Param("id")
+
+	songId, err := primitive.ObjectIDFromHex(c.Param("songId"))
+	if err != nil || !h.channelExists(c, channelId) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	likes, err := like(channelId, auth.ExtractClaimsFromContext(c).Id, songId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.Status(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if message, err := likeMessage(songId, likes); err != nil {
+		log.Println(err)
+	} else if err := broadcastMessage(websocket.TextMessage, message, channelId); err != nil {
+		log.Println(err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"likes": likes,
+	})
+}
+
+func (h *Handler) PostLike(c *gin.Context) {
+	h.respondToLike(c, h.LikeSong)
+}
+
+func (h *Handler) DeleteLike(c *gin.Context) {
+	h.respondToLike(c, h.UnlikeSong)
+}
+
+// aggregateLikes groups likes of songs played in the channel within the window (e.g. "week") by the field
+func (h *Handler) aggregateLikes(c context.Context, channelId, window, groupBy string, limit int) ([]bson.M, error) {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, exists := leaderboardWindows[window]
+	if !exists {
+		return nil, errors.New("invalid window")
+	}
+
+	match := bson.M{"channel_id": channelObjId}
+	if duration != 0 {
+		match["song.song_start_time"] = bson.M{"$gte": time.Now().Add(-duration).UnixMilli()}
+	}
+
+	if groupBy == "song.added_by" {
+		// songs added by the server don't have a contributor
+		match["song.added_by"] = bson.M{"$nin": []interface{}{"", nil}}
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		// $last takes the most recently played song of the group only when input is sorted
+		{"$sort": bson.M{"song.song_start_time": 1}},
+		{"$group": bson.M{
+			"_id":   "$" + groupBy,
+			"likes": bson.M{"$sum": 1},
+			"song":  bson.M{"$last": "$song"},
+		}},
+		{"$sort": bson.D{{Key: "likes", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$limit": limit},
+	}
+
+	cursor, err := h.Db.Collection("song_like").Aggregate(c, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []bson.M{}
+	if err := cursor.All(c, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (h *Handler) respondWithLeaderboard(c *gin.Context, groupBy string, format func(bson.M) gin.H) {
+	channelId := c.Param("id")
+	if !h.channelExists(c, channelId) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	window := c.DefaultQuery("window", "week")
+	if _, exists := leaderboardWindows[window]; !exists {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.aggregateLikes(c, channelId, window, groupBy, leaderboardLimit)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	leaderboard := make([]gin.H, 0, len(results))
+	for _, result := range results {
+		leaderboard = append(leaderboard, format(result))
+	}
+
+	c.JSON(http.StatusOK, leaderboard)
+}
+
+// GetTopSongs returns the most liked songs of the channel within the window ("day", "week", "month" or "all")
+func (h *Handler) GetTopSongs(c *gin.Context) {
+	h.respondWithLeaderboard(c, "song.song_id", func(result bson.M) gin.H {
+		return gin.H{
+			"song":  result["song"],
+			"likes": result["likes"],
+		}
+	})
+}
+
+// GetTopDJs returns users whose songs got the most likes in the channel within the window
+func (h *Handler) GetTopDJs(c *gin.Context) {
+	h.respondWithLeaderboard(c, "song.added_by", func(result bson.M) gin.H {
+		return gin.H{
+			"user":  result["_id"],
+			"likes": result["likes"],
+		}
+	})
+}
+
+// likedAutoplaySource returns the most liked songs of the channel
+func likedAutoplaySource(h *Handler, channel models.Channel, queue models.Queue) ([]models.Song, error) {
+	results, err := h.aggregateLikes(context.Background(), channel.Id, "all", "song.song_id", autoplayCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]models.Song, 0, len(results))
+	for _, result := range results {
+		data, err := bson.Marshal(result["song"])
+		if err != nil {
+			return nil, err
+		}
+
+		var song models.Song
+		if err := bson.Unmarshal(data, &song); err != nil {
+			return nil, err
+		}
+
+		songs = append(songs, song)
+	}
+
+	return songs, nil
+}
@@ -0,0 +1,67 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"nbeat-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func playbackMessage(queue models.Queue) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":    "playback",
+		"content": queue.Status(),
+	})
+}
+
+// fetchPlaybackStatus returns playback status of the channel, channels without queue are considered playing
+func (h *Handler) fetchPlaybackStatus(channelId string) (models.PlaybackStatus, error) {
+	queue, err := h.fetchQueue(channelId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.PlaybackStatus{State: models.PlaybackPlaying}, nil
+	} else if err != nil {
+		return models.PlaybackStatus{}, err
+	}
+
+	return queue.Status(), nil
+}
+
+func (h *Handler) PausePlayback(channelId string) (models.Queue, error) {
+	return h.updateQueue(channelId, func(queue *models.Queue) error {
+		return queue.Pause(time.Now().UnixMilli())
+	})
+}
+
+func (h *Handler) ResumePlayback(channelId string) (models.Queue, error) {
+	return h.updateQueue(channelId, func(queue *models.Queue) error {
+		return queue.Resume(time.Now().UnixMilli())
+	})
+}
+
+// handlePlaybackMessage pauses or resumes playback in the channel, only moderators can do it
+func (h *Handler) handlePlaybackMessage(messageType, channelId, userId string) ([]byte, error) {
+	channel, err := h.fetchChannel(context.Background(), channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !channel.IsModerator(userId) {
+		return nil, errForbidden
+	}
+
+	var queue models.Queue
+	if messageType == MessageTypePause {
+		queue, err = h.PausePlayback(channelId)
+	} else {
+		queue, err = h.ResumePlayback(channelId)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return playbackMessage(queue)
+}
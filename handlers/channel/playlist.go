@@ -0,0 +1,172 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"nbeat-api/db"
+	"nbeat-api/middleware/auth"
+	"nbeat-api/models"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// announceSongs saves messages about songs added to the queue at once and returns them grouped in one message
+func (h *Handler) announceSongs(songs []models.Song, channelId, author string) ([]byte, error) {
+	messages := make([]models.Message, 0, len(songs))
+	for _, song := range songs {
+		messages = append(messages, models.Message{
+			Author:  author,
+			Type:    "song",
+			Id:      song.Id,
+			SongRef: song.Id,
+		})
+	}
+
+	if err := h.saveMessages(messages, channelId); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"author":  author,
+		"content": songs,
+		"type":    "songs",
+	})
+}
+
+// EnqueuePlaylist adds all songs of the playlist to the channel's queue in one step
+func (h *Handler) EnqueuePlaylist(c *gin.Context) {
+	channelId := c.Param("id")
+	userId := auth.ExtractClaimsFromContext(c).Id
+
+	playlist, err := db.FetchPlaylist(c, h.Db, c.Param("playlistId"))
+	if err != nil || !playlist.CanView(userId) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if len(playlist.Songs) == 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	channel, err := h.fetchChannel(c, channelId)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	songs := make([]models.Song, 0, len(playlist.Songs))
+	for _, song := range playlist.Songs {
+		song = song.NewEntry()
+		song.AddedBy = userId
+		songs = append(songs, song)
+	}
+
+	// songs of listeners in moderated channels wait for approval like ones posted in the chat
+	if channel.Settings.ModeratedRequests && !channel.IsCurator(userId) {
+		requested, rejected := h.filterRequests(channel, songs)
+		if err := h.SubmitRequests(channel, requested); err != nil {
+			log.Println(err)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"requested": requested,
+			"rejected":  rejected,
+		})
+		return
+	}
+
+	scheduled, rejected, err := h.PlaySongs(songs, channelId)
+
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		c.JSON(http.StatusConflict, rejection)
+		return
+	} else if errors.Is(err, mongo.ErrNoDocuments) {
+		c.Status(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	message, err := h.announceSongs(scheduled, channelId, userId)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if err := broadcastMessage(websocket.TextMessage, message, channelId); err != nil {
+		log.Println(err)
+	}
+
+	// other songs could have been moved to make room for the new ones
+	if err := h.broadcastQueue(channelId); err != nil {
+		log.Println(err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"songs":    scheduled,
+		"rejected": rejected,
+	})
+}
+
+// playlistAutoplaySource returns songs of the playlist starting after the last one played in the channel
+func playlistAutoplaySource(h *Handler, channel models.Channel, queue models.Queue) ([]models.Song, error) {
+	playlist, err := db.FetchPlaylist(context.Background(), h.Db, channel.Settings.Autoplay.PlaylistId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// private playlists can be played only in channels of their owners
+	if !playlist.CanView(channel.Owner) {
+		return nil, nil
+	}
+
+	lastSong, err := h.fetchLastPlayedSong(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if idx := slices.IndexFunc(playlist.Songs, func(s models.Song) bool { return s.SongId == lastSong.SongId }); idx != -1 {
+		start = idx + 1
+	}
+
+	songs := make([]models.Song, 0, len(playlist.Songs))
+	songs = append(songs, playlist.Songs[start:]...)
+	songs = append(songs, playlist.Songs[:start]...)
+
+	return songs[:min(len(songs), autoplayCandidates)], nil
+}
+
+// fetchLastPlayedSong returns the last song of the queue, or the latest archived one if the queue is empty
+func (h *Handler) fetchLastPlayedSong(queue models.Queue) (models.Song, error) {
+	if len(queue.Songs) > 0 {
+		return queue.Songs[len(queue.Songs)-1], nil
+	}
+
+	opts := options.FindOne().SetSort(bson.M{"song_start_time": -1})
+
+	var entry models.HistorySong
+	err := h.Db.Collection("song_history").FindOne(context.Background(), bson.M{"channel_id": queue.ChannelId}, opts).Decode(&entry)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Song{}, err
+	}
+
+	return entry.Song, nil
+}
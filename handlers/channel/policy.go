@@ -0,0 +1,245 @@
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"nbeat-api/media"
+	"nbeat-api/models"
+	"slices"
+	"time"
+)
+
+const (
+	RejectionUserQueueLimit = "user_queue_limit"
+	RejectionSongTooLong    = "song_too_long"
+	RejectionQueueFull      = "queue_full"
+	RejectionDuplicateSong  = "duplicate_song"
+	RejectionSongOnCooldown = "song_on_cooldown"
+	RejectionSongNotFound   = "song_not_found"
+	RejectionProviderError  = "provider_unavailable"
+	RejectionSongRestricted = "song_restricted"
+	RejectionSongBlocked    = "song_blocked"
+)
+
+var restrictionDescriptions = map[string]string{
+	models.SongLive:          "live streams can't be queued",
+	models.SongNotEmbeddable: "song can't be played outside of its site",
+	models.SongRegionBlocked: "song isn't available in this region",
+	models.SongAgeRestricted: "song is age restricted",
+}
+
+var blockedDescriptions = map[string]string{
+	models.BlockedSong:     "song is blocked in this channel",
+	models.BlockedUploader: "songs of this uploader are blocked in this channel",
+	models.BlockedKeyword:  "song title contains a blocked keyword",
+}
+
+// checkBlocklist rejects songs blocked in the channel
+func checkBlocklist(channel models.Channel, song models.Song) error {
+	if blocked := channel.Blocklist.Match(song); blocked != "" {
+		return &Rejection{
+			Reason:  RejectionSongBlocked,
+			Message: blockedDescriptions[blocked],
+			Details: map[string]interface{}{"blocked": blocked},
+		}
+	}
+
+	return nil
+}
+
+// filterRequests splits songs submitted for approval into allowed ones and rejections of blocked ones.
+// Requests pass the channel's rules only when approved, blocked songs aren't worth asking about.
+func (h *Handler) filterRequests(channel models.Channel, songs []models.Song) ([]models.Song, []SongRejection) {
+	var skipped []SongRejection
+	allowed := make([]models.Song, 0, len(songs))
+	for _, song := range h.resolveUploaders(channel, songs) {
+		var rejection *Rejection
+		if errors.As(checkBlocklist(channel, song), &rejection) {
+			skipped = append(skipped, SongRejection{Song: song, Rejection: rejection})
+			continue
+		}
+
+		allowed = append(allowed, song)
+	}
+
+	return allowed, skipped
+}
+
+// resolveUploaders fetches uploaders of songs which were saved without them (e.g. in playlists),
+// so songs of blocked uploaders can be recognized
+func (h *Handler) resolveUploaders(channel models.Channel, songs []models.Song) []models.Song {
+	if len(channel.Blocklist.Uploaders) == 0 || h.Providers == nil {
+		return songs
+	}
+
+	// ids of songs without uploaders grouped by provider, so each provider is asked once
+	missing := map[string][]string{}
+	for _, song := range songs {
+		if song.UploaderId == "" && !slices.Contains(missing[songProvider(song)], song.SongId) {
+			missing[songProvider(song)] = append(missing[songProvider(song)], song.SongId)
+		}
+	}
+
+	uploaders := map[string]string{}
+	for providerName, ids := range missing {
+		provider, exists := h.Providers.Get(providerName)
+		if !exists {
+			continue
+		}
+
+		for _, data := range fetchSongs(provider, ids) {
+			uploaders[providerName+":"+data.SongId] = data.UploaderId
+		}
+	}
+
+	resolved := make([]models.Song, 0, len(songs))
+	for _, song := range songs {
+		if song.UploaderId == "" {
+			song.UploaderId = uploaders[songProvider(song)+":"+song.SongId]
+		}
+
+		resolved = append(resolved, song)
+	}
+
+	return resolved
+}
+
+// songProvider returns name of the provider the song comes from,
+// songs saved before other providers were added have none set
+func songProvider(song models.Song) string {
+	if song.Provider == "" {
+		return media.ProviderYouTube
+	}
+
+	return song.Provider
+}
+
+// fetchSongs fetches the songs in batches when the provider supports it, songs which failed are left out
+func fetchSongs(provider media.MediaProvider, ids []string) []models.Song {
+	if batchProvider, ok := provider.(media.BatchProvider); ok {
+		songs, err := batchProvider.FetchSongs(ids)
+		if err != nil {
+			log.Println(err)
+		}
+
+		return songs
+	}
+
+	songs := make([]models.Song, 0, len(ids))
+	for _, id := range ids {
+		song, err := provider.FetchSong(id)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		songs = append(songs, song)
+	}
+
+	return songs
+}
+
+// checkRestrictions rejects songs with restrictions the channel doesn't allow
+func checkRestrictions(channel models.Channel, song models.Song) error {
+	rejected := channel.Settings.GetRejectRestrictions()
+
+	for _, restriction := range song.Restrictions {
+		if slices.Contains(rejected, restriction) {
+			return &Rejection{
+				Reason:  RejectionSongRestricted,
+				Message: restrictionDescriptions[restriction],
+				Details: map[string]interface{}{"restriction": restriction},
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkQueuePolicy checks whether the user who added the song can add it to the channel's queue.
+// Owner and DJs of the channel and songs added by the server are not limited.
+func checkQueuePolicy(channel models.Channel, queue models.Queue, song models.Song) error {
+	userId := song.AddedBy
+	if userId == "" || channel.IsDJ(userId) {
+		return nil
+	}
+
+	settings := channel.Settings
+
+	if settings.MaxSongDuration != 0 && song.PlayDuration() > settings.MaxSongDuration {
+		return &Rejection{
+			Reason:  RejectionSongTooLong,
+			Message: fmt.Sprintf("song is longer than %.0f seconds", settings.MaxSongDuration),
+			Details: map[string]interface{}{"max_song_duration": settings.MaxSongDuration},
+		}
+	}
+
+	upcoming := queue.UpcomingSongs(queue.Now())
+
+	if settings.MaxQueueLength != 0 && len(upcoming) >= settings.MaxQueueLength {
+		return &Rejection{
+			Reason:  RejectionQueueFull,
+			Message: "queue is full",
+			Details: map[string]interface{}{"max_queue_length": settings.MaxQueueLength},
+		}
+	}
+
+	if settings.MaxPendingSongsPerUser != 0 {
+		pending := 0
+		for _, s := range upcoming {
+			if s.AddedBy == userId {
+				pending++
+			}
+		}
+
+		if pending >= settings.MaxPendingSongsPerUser {
+			return &Rejection{
+				Reason:  RejectionUserQueueLimit,
+				Message: fmt.Sprintf("you already have %d songs in the queue", pending),
+				Details: map[string]interface{}{"max_pending_songs_per_user": settings.MaxPendingSongsPerUser},
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkReplay rejects songs which are already pending in the queue or were played within the channel's cooldown
+func (h *Handler) checkReplay(queue models.Queue, song models.Song, cooldown int64) error {
+	now := queue.Now()
+	cooldownMs := cooldown * 1000
+
+	entry, found := queue.FindRecentSong(song.SongId, now-cooldownMs, now)
+	if !found && cooldown > 0 {
+		var err error
+		if entry, found, err = h.findRecentlyPlayed(queue.ChannelId, song.SongId, now-cooldownMs); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	availableAt := max(entry.EndTime(), entry.SongStartTime+cooldownMs)
+
+	rejection := &Rejection{
+		Reason:  RejectionSongOnCooldown,
+		Message: fmt.Sprintf("song was played recently, it can be played again at %s", time.UnixMilli(availableAt).UTC().Format(time.RFC3339)),
+		Details: map[string]interface{}{
+			"song":         entry,
+			"available_at": availableAt,
+		},
+	}
+
+	if entry.SongStartTime > now {
+		rejection.Reason = RejectionDuplicateSong
+		rejection.Message = "song is already in the queue"
+	} else if entry.EndTime() > now {
+		rejection.Reason = RejectionDuplicateSong
+		rejection.Message = "song is playing right now"
+	}
+
+	return rejection
+}
@@ -0,0 +1,311 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"nbeat-api/middleware/auth"
+	"nbeat-api/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (h *Handler) fetchQueue(channelId string) (models.Queue, error) {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return models.Queue{}, err
+	}
+
+	var queue models.Queue
+	filter := bson.M{"channel_id": channelObjId}
+	if err := h.Db.Collection("queue").FindOne(context.Background(), filter).Decode(&queue); err != nil {
+		return models.Queue{}, err
+	}
+
+	return queue, nil
+}
+
+// maxQueueUpdateAttempts is high enough for bursts of concurrent updates (e.g. many users adding songs at once)
+const maxQueueUpdateAttempts = 50
+
+var errQueueConflict = errors.New("queue was modified concurrently")
+
+// updateQueue loads queue of the channel, applies update to it and saves the result.
+// Queue is saved only if it wasn't modified in the meantime, otherwise update is applied again
+// to the fresh queue, so it must not change anything but the queue it gets. It may read other data
+// (e.g. song history), which is read again on every attempt as other updates could change it too.
+// Songs which finished playing are archived once the queue is saved.
+func (h *Handler) updateQueue(channelId string, update func(queue *models.Queue) error) (models.Queue, error) {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return models.Queue{}, err
+	}
+
+	load := func() (models.Queue, error) {
+		var queue models.Queue
+		err := h.Db.Collection("queue").FindOne(context.Background(), bson.M{"channel_id": channelObjId}).Decode(&queue)
+		return queue, err
+	}
+
+	queue, err := retryQueueUpdate(load, h.saveQueue, update)
+	if err != nil {
+		return models.Queue{}, err
+	}
+
+	if err := h.archivePlayedSongs(&queue); err != nil {
+		// songs stay in the queue and are archived by one of the next updates
+		log.Println(err)
+	}
+
+	return queue, nil
+}
+
+// retryQueueUpdate applies update to the loaded queue and saves it until save succeeds, save reports false
+// when the queue was modified since it was loaded
+func retryQueueUpdate(load func() (models.Queue, error), save func(queue models.Queue) (bool, error), update func(queue *models.Queue) error) (models.Queue, error) {
+	for attempt := 0; attempt < maxQueueUpdateAttempts; attempt++ {
+		queue, err := load()
+		if err != nil {
+			return models.Queue{}, err
+		}
+
+		if err := update(&queue); err != nil {
+			return models.Queue{}, err
+		}
+
+		saved, err := save(queue)
+		if err != nil {
+			return models.Queue{}, err
+		}
+
+		if saved {
+			queue.Version++
+			return queue, nil
+		}
+
+		time.Sleep(time.Duration(rand.Intn(10*(attempt+1))) * time.Millisecond)
+	}
+
+	return models.Queue{}, errQueueConflict
+}
+
+// saveQueue replaces the queue with the given one unless its version changed since it was loaded
+func (h *Handler) saveQueue(queue models.Queue) (bool, error) {
+	filter := bson.M{"_id": queue.Id, "version": queue.Version}
+	if queue.Version == 0 {
+		// queues created before versioning don't have the field
+		filter["version"] = bson.M{"$in": []interface{}{0, nil}}
+	}
+
+	queue.Version++
+
+	res, err := h.Db.Collection("queue").ReplaceOne(context.Background(), filter, queue)
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount == 1, nil
+}
+
+var errForbidden = errors.New("forbidden")
+
+// RemoveQueuedSong removes upcoming song from the queue, songs can be removed by moderators
+// and by users who added them
+func (h *Handler) RemoveQueuedSong(channelId, userId string, songId primitive.ObjectID) error {
+	channel, err := h.fetchChannel(context.Background(), channelId)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.updateQueue(channelId, func(queue *models.Queue) error {
+		now := queue.Now()
+
+		song, err := queue.FindUpcomingSong(songId, now)
+		if err != nil {
+			return err
+		}
+
+		if !channel.IsModerator(userId) && song.AddedBy != userId {
+			return errForbidden
+		}
+
+		return queue.RemoveSong(songId, now)
+	})
+
+	return err
+}
+
+// MoveQueuedSong moves upcoming song to the position within upcoming songs, only moderators can reorder the queue
+func (h *Handler) MoveQueuedSong(channelId, userId string, songId primitive.ObjectID, position int) error {
+	channel, err := h.fetchChannel(context.Background(), channelId)
+	if err != nil {
+		return err
+	}
+
+	if !channel.IsModerator(userId) {
+		return errForbidden
+	}
+
+	_, err = h.updateQueue(channelId, func(queue *models.Queue) error {
+		return queue.MoveSong(songId, position, queue.Now())
+	})
+
+	return err
+}
+
+// queueMessage builds message with upcoming songs of the channel
+func (h *Handler) queueMessage(channelId string) ([]byte, error) {
+	upcoming, err := h.FetchUpcomingSongsForChannel(context.Background(), channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"type":    "queue",
+		"content": upcoming,
+	})
+}
+
+func (h *Handler) handleQueueMessage(messageType, content, channelId, userId string) ([]byte, error) {
+	args := strings.Fields(content)
+	if len(args) == 0 {
+		return nil, errors.New("missing song id")
+	}
+
+	songId, err := primitive.ObjectIDFromHex(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	switch messageType {
+	case MessageTypeRemove:
+		err = h.RemoveQueuedSong(channelId, userId, songId)
+	case MessageTypePlayNext:
+		err = h.MoveQueuedSong(channelId, userId, songId, 0)
+	case MessageTypeMove:
+		if len(args) != 2 {
+			return nil, errors.New("missing position")
+		}
+
+		position, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return nil, convErr
+		}
+
+		err = h.MoveQueuedSong(channelId, userId, songId, position)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return h.queueMessage(channelId)
+}
+
+func queueErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrSongNotQueued), errors.Is(err, mongo.ErrNoDocuments):
+		return http.StatusNotFound
+	case errors.Is(err, errForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *Handler) broadcastQueue(channelId string) error {
+	message, err := h.queueMessage(channelId)
+	if err != nil {
+		return err
+	}
+
+	return broadcastMessage(websocket.TextMessage, message, channelId)
+}
+
+// respondWithQueue broadcasts upcoming songs of the channel after queue was changed through REST
+func (h *Handler) respondWithQueue(c *gin.Context, channelId string) {
+	if err := h.broadcastQueue(channelId); err != nil {
+		log.Println(err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) RemoveFromQueue(c *gin.Context) {
+	channelId := c.Param("id")
+
+	songId, err := primitive.ObjectIDFromHex(c.Param("songId"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	claims := auth.ExtractClaimsFromContext(c)
+
+	if err := h.RemoveQueuedSong(channelId, claims.Id, songId); err != nil {
+		log.Println(err)
+		c.Status(queueErrorStatus(err))
+		return
+	}
+
+	h.respondWithQueue(c, channelId)
+}
+
+func (h *Handler) MoveInQueue(c *gin.Context) {
+	channelId := c.Param("id")
+
+	songId, err := primitive.ObjectIDFromHex(c.Param("songId"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		Position *int `json:"position"`
+	}
+
+	if err := c.BindJSON(&body); err != nil || body.Position == nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	claims := auth.ExtractClaimsFromContext(c)
+
+	if err := h.MoveQueuedSong(channelId, claims.Id, songId, *body.Position); err != nil {
+		log.Println(err)
+		c.Status(queueErrorStatus(err))
+		return
+	}
+
+	h.respondWithQueue(c, channelId)
+}
+
+func (h *Handler) PlayNext(c *gin.Context) {
+	channelId := c.Param("id")
+
+	songId, err := primitive.ObjectIDFromHex(c.Param("songId"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	claims := auth.ExtractClaimsFromContext(c)
+
+	if err := h.MoveQueuedSong(channelId, claims.Id, songId, 0); err != nil {
+		log.Println(err)
+		c.Status(queueErrorStatus(err))
+		return
+	}
+
+	h.respondWithQueue(c, channelId)
+}
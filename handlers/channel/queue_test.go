@@ -0,0 +1,171 @@
+package channel
+
+import (
+	"fmt"
+	"nbeat-api/models"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestConcurrentPlaySongLosesNoSongs(t *testing.T) {
+	h := newTestHandler(t)
+	channelId := insertTestChannel(t, h, "owner", bson.M{})
+
+	const songs = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, songs)
+	added := make(chan primitive.ObjectID, songs)
+
+	for i := 0; i < songs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			song, err := h.PlaySong(models.Song{
+				Id:       primitive.NewObjectID(),
+				SongId:   fmt.Sprintf("song-%d", i),
+				Duration: 60,
+				AddedBy:  fmt.Sprintf("user-%d", i),
+			}, channelId)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			added <- song.Id
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+	close(added)
+
+	for err := range errs {
+		t.Errorf("PlaySong failed: %v", err)
+	}
+
+	queue, err := h.fetchQueue(channelId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkQueuedSongs(t, queue, added, songs)
+}
+
+// memoryQueue stores a queue in memory with the same versioning as the queue collection
+type memoryQueue struct {
+	mu    sync.Mutex
+	queue models.Queue
+}
+
+func (m *memoryQueue) load() (models.Queue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue := m.queue
+	queue.Songs = slices.Clone(m.queue.Songs)
+
+	return queue, nil
+}
+
+func (m *memoryQueue) save(queue models.Queue) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if queue.Version != m.queue.Version {
+		return false, nil
+	}
+
+	queue.Version++
+	m.queue = queue
+
+	return true, nil
+}
+
+func TestConcurrentQueueUpdatesLoseNoSongs(t *testing.T) {
+	h := &Handler{}
+	store := &memoryQueue{}
+
+	const songs = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, songs)
+	added := make(chan primitive.ObjectID, songs)
+
+	for i := 0; i < songs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			song := models.Song{
+				Id:       primitive.NewObjectID(),
+				SongId:   fmt.Sprintf("song-%d", i),
+				Duration: 60,
+				AddedBy:  fmt.Sprintf("user-%d", i),
+			}
+
+			_, err := retryQueueUpdate(store.load, store.save, func(queue *models.Queue) error {
+				if err := h.scheduleSong(models.Channel{}, queue, song); err != nil {
+					return err
+				}
+
+				// give other updates time to save the queue in the meantime
+				time.Sleep(time.Millisecond)
+
+				return nil
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			added <- song.Id
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+	close(added)
+
+	for err := range errs {
+		t.Errorf("update failed: %v", err)
+	}
+
+	queue, _ := store.load()
+	checkQueuedSongs(t, queue, added, songs)
+}
+
+// checkQueuedSongs checks that every added song is in the queue once, scheduled right after the previous one,
+// and the queue was saved once per song
+func checkQueuedSongs(t *testing.T, queue models.Queue, added <-chan primitive.ObjectID, songs int) {
+	t.Helper()
+
+	if len(queue.Songs) != songs {
+		t.Fatalf("queue has %d songs, want %d", len(queue.Songs), songs)
+	}
+
+	queued := make(map[primitive.ObjectID]struct{}, songs)
+	for i, song := range queue.Songs {
+		queued[song.Id] = struct{}{}
+
+		if i > 0 && song.SongStartTime != queue.Songs[i-1].EndTime() {
+			t.Errorf("song %d starts at %d, want end of the previous one %d", i, song.SongStartTime, queue.Songs[i-1].EndTime())
+		}
+	}
+
+	for id := range added {
+		if _, exists := queued[id]; !exists {
+			t.Errorf("song %s was reported as added but isn't in the queue", id.Hex())
+		}
+	}
+
+	if queue.Version != int64(songs) {
+		t.Errorf("queue version = %d, want one update per song (%d)", queue.Version, songs)
+	}
+}
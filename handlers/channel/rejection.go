@@ -0,0 +1,92 @@
+package channel
+
+import (
+	"encoding/json"
+	"errors"
+	"nbeat-api/media"
+	"nbeat-api/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Rejection is returned when a request from the websocket can't be fulfilled because of the channel's rules,
+// instead of being only logged it is sent back to the sender
+type Rejection struct {
+	Reason  string      `json:"reason"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// SongRejection tells why the song wasn't added to the queue together with other songs
+type SongRejection struct {
+	Song      models.Song `json:"song"`
+	Rejection *Rejection  `json:"rejection"`
+}
+
+// notifySkippedSongs tells the sender how many of the songs added at once weren't queued and why
+func notifySkippedSongs(channelId, senderId string, skipped []SongRejection) error {
+	if len(skipped) == 0 {
+		return nil
+	}
+
+	message, err := json.Marshal(map[string]interface{}{
+		"type": "skipped",
+		"content": map[string]interface{}{
+			"count": len(skipped),
+			"songs": skipped,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendToUsers(websocket.TextMessage, message, channelId, func(userId string) bool {
+		return userId == senderId
+	})
+}
+
+// providerRejection converts errors of media providers into rejections the sender can understand
+func providerRejection(err error) error {
+	switch {
+	case errors.Is(err, media.ErrNotFound):
+		return &Rejection{
+			Reason:  RejectionSongNotFound,
+			Message: "song not found",
+		}
+	case errors.Is(err, media.ErrQuotaExceeded):
+		return &Rejection{
+			Reason:  RejectionProviderError,
+			Message: "song provider limit reached, try again later",
+		}
+	case errors.Is(err, media.ErrInvalidKey):
+		return &Rejection{
+			Reason:  RejectionProviderError,
+			Message: "song provider is misconfigured",
+		}
+	}
+
+	return err
+}
+
+func sendMessage(conn *websocket.Conn, messageType int, message []byte) error {
+	clientRegistry.m.Lock()
+	defer clientRegistry.m.Unlock()
+
+	return conn.WriteMessage(messageType, message)
+}
+
+func sendRejection(conn *websocket.Conn, rejection *Rejection) error {
+	message, err := json.Marshal(map[string]interface{}{
+		"type":    "rejection",
+		"content": rejection,
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendMessage(conn, websocket.TextMessage, message)
+}
@@ -0,0 +1,296 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"nbeat-api/middleware/auth"
+	"nbeat-api/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notifyAboutRequest sends message about the request to its author and curators of the channel
+func notifyAboutRequest(channel models.Channel, request models.SongRequest, messageType string, content interface{}) error {
+	message, err := json.Marshal(map[string]interface{}{
+		"type":    messageType,
+		"content": content,
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendToUsers(websocket.TextMessage, message, channel.Id, func(userId string) bool {
+		return userId == request.UserId || channel.IsCurator(userId)
+	})
+}
+
+// notifyRequesterAboutRejection tells the author of the request why its song couldn't be played
+func notifyRequesterAboutRejection(channel models.Channel, request models.SongRequest, rejection *Rejection) error {
+	message, err := json.Marshal(map[string]interface{}{
+		"type":    "rejection",
+		"content": rejection,
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendToUsers(websocket.TextMessage, message, channel.Id, func(userId string) bool {
+		return userId == request.UserId
+	})
+}
+
+// SubmitRequest saves the song as waiting for approval of the channel's DJs or moderators
+func (h *Handler) SubmitRequest(channel models.Channel, song models.Song) error {
+	channelObjId, err := primitive.ObjectIDFromHex(channel.Id)
+	if err != nil {
+		return err
+	}
+
+	request := models.SongRequest{
+		ChannelId: channelObjId,
+		UserId:    song.AddedBy,
+		CreatedAt: time.Now().UnixMilli(),
+		Song:      song,
+	}
+
+	res, err := h.Db.Collection("song_request").InsertOne(context.Background(), request)
+	if err != nil {
+		return err
+	}
+
+	request.Id = res.InsertedID.(primitive.ObjectID)
+
+	return notifyAboutRequest(channel, request, "request", request)
+}
+
+// SubmitRequests saves songs added at once (e.g. from a playlist) as waiting for approval,
+// curators are notified about all of them in one message
+func (h *Handler) SubmitRequests(channel models.Channel, songs []models.Song) error {
+	if len(songs) == 0 {
+		return nil
+	}
+
+	channelObjId, err := primitive.ObjectIDFromHex(channel.Id)
+	if err != nil {
+		return err
+	}
+
+	createdAt := time.Now().UnixMilli()
+	requests := make([]models.SongRequest, 0, len(songs))
+	documents := make([]interface{}, 0, len(songs))
+	for _, song := range songs {
+		request := models.SongRequest{
+			Id:        primitive.NewObjectID(),
+			ChannelId: channelObjId,
+			UserId:    song.AddedBy,
+			CreatedAt: createdAt,
+			Song:      song,
+		}
+		requests = append(requests, request)
+		documents = append(documents, request)
+	}
+
+	if _, err := h.Db.Collection("song_request").InsertMany(context.Background(), documents); err != nil {
+		return err
+	}
+
+	return notifyAboutRequest(channel, requests[0], "requests", requests)
+}
+
+// claimRequest removes the request from waiting ones and returns it, so only one decision about it is made
+func (h *Handler) claimRequest(channelId string, requestId primitive.ObjectID) (models.SongRequest, error) {
+	channelObjId, err := primitive.ObjectIDFromHex(channelId)
+	if err != nil {
+		return models.SongRequest{}, err
+	}
+
+	var request models.SongRequest
+	filter := bson.M{"_id": requestId, "channel_id": channelObjId}
+	if err := h.Db.Collection("song_request").FindOneAndDelete(context.Background(), filter).Decode(&request); err != nil {
+		return models.SongRequest{}, err
+	}
+
+	return request, nil
+}
+
+// ApproveRequest adds the requested song to the queue and returns message about it
+func (h *Handler) ApproveRequest(channelId, userId string, requestId primitive.ObjectID) ([]byte, error) {
+	channel, err := h.fetchChannel(context.Background(), channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !channel.IsCurator(userId) {
+		return nil, errForbidden
+	}
+
+	request, err := h.claimRequest(channelId, requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	song, err := h.PlaySong(request.Song, channelId)
+
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		// the song can't be played, so the request is rejected instead
+		if err := notifyRequesterAboutRejection(channel, request, rejection); err != nil {
+			log.Println(err)
+		}
+		if err := notifyAboutRequest(channel, request, "request_rejected", request); err != nil {
+			log.Println(err)
+		}
+		return nil, err
+	} else if err != nil {
+		// put the request back so it can be decided about again
+		if _, insertErr := h.Db.Collection("song_request").InsertOne(context.Background(), request); insertErr != nil {
+			log.Println(insertErr)
+		}
+		return nil, err
+	}
+
+	if err := notifyAboutRequest(channel, request, "request_approved", request); err != nil {
+		log.Println(err)
+	}
+
+	if channel.Settings.FairQueue {
+		if err := h.broadcastQueue(channelId); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return h.announceSong(song, channelId, request.UserId)
+}
+
+func (h *Handler) RejectRequest(channelId, userId string, requestId primitive.ObjectID) error {
+	channel, err := h.fetchChannel(context.Background(), channelId)
+	if err != nil {
+		return err
+	}
+
+	if !channel.IsCurator(userId) {
+		return errForbidden
+	}
+
+	request, err := h.claimRequest(channelId, requestId)
+	if err != nil {
+		return err
+	}
+
+	return notifyAboutRequest(channel, request, "request_rejected", request)
+}
+
+func (h *Handler) handleRequestDecisionMessage(messageType, content, channelId, userId string) ([]byte, error) {
+	requestId, err := primitive.ObjectIDFromHex(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if messageType == MessageTypeApprove {
+		return h.ApproveRequest(channelId, userId, requestId)
+	}
+
+	return nil, h.RejectRequest(channelId, userId, requestId)
+}
+
+// GetRequests returns songs waiting for approval, only for DJs and moderators of the channel
+func (h *Handler) GetRequests(c *gin.Context) {
+	channel, err := h.fetchChannel(c, c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if !channel.IsCurator(auth.ExtractClaimsFromContext(c).Id) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	channelObjId, err := primitive.ObjectIDFromHex(channel.Id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": 1})
+
+	cursor, err := h.Db.Collection("song_request").Find(c, bson.M{"channel_id": channelObjId}, opts)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	requests := []models.SongRequest{}
+	if err := cursor.All(c, &requests); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func requestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return http.StatusNotFound
+	case errors.Is(err, errForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *Handler) PostApproveRequest(c *gin.Context) {
+	channelId := c.Param("id")
+
+	requestId, err := primitive.ObjectIDFromHex(c.Param("requestId"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	message, err := h.ApproveRequest(channelId, auth.ExtractClaimsFromContext(c).Id, requestId)
+
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		c.JSON(http.StatusConflict, rejection)
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.Status(requestErrorStatus(err))
+		return
+	}
+
+	if err := broadcastMessage(websocket.TextMessage, message, channelId); err != nil {
+		log.Println(err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) PostRejectRequest(c *gin.Context) {
+	requestId, err := primitive.ObjectIDFromHex(c.Param("requestId"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if err := h.RejectRequest(c.Param("id"), auth.ExtractClaimsFromContext(c).Id, requestId); err != nil {
+		log.Println(err)
+		c.Status(requestErrorStatus(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
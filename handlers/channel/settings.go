@@ -0,0 +1,101 @@
+package channel
+
+import (
+	"log"
+	"nbeat-api/middleware/auth"
+	"nbeat-api/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// updateOwnedChannel applies update to the channel if it belongs to the user from request
+func (h *Handler) updateOwnedChannel(c *gin.Context, update bson.M) {
+	userId := auth.ExtractClaimsFromContext(c).Id
+
+	h.updateChannel(c, bson.M{"owner": userId}, update)
+}
+
+// updateModeratedChannel applies update to the channel if the user from request is its moderator
+func (h *Handler) updateModeratedChannel(c *gin.Context, update bson.M) {
+	userId := auth.ExtractClaimsFromContext(c).Id
+
+	h.updateChannel(c, bson.M{"$or": []bson.M{{"owner": userId}, {"moderators": userId}}}, update)
+}
+
+// updateChannel applies update to the channel from request if it matches roleFilter
+func (h *Handler) updateChannel(c *gin.Context, roleFilter bson.M, update bson.M) {
+	channelObjId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	filter := bson.M{
+		"_id":        channelObjId,
+		"deleted_at": bson.M{"$exists": false},
+	}
+	for key, value := range roleFilter {
+		filter[key] = value
+	}
+
+	if res, err := h.Db.Collection("channel").UpdateOne(c, filter, update); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	} else if res.MatchedCount == 0 {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	var settings models.Settings
+
+	if err := c.BindJSON(&settings); err != nil {
+		log.Println(err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := settings.Validate(); err != nil {
+		log.Println(err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	h.updateOwnedChannel(c, bson.M{"$set": bson.M{"settings": settings}})
+}
+
+func (h *Handler) addToRole(c *gin.Context, role string) {
+	var body struct {
+		Id string `json:"id"`
+	}
+
+	if err := c.BindJSON(&body); err != nil || body.Id == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	h.updateOwnedChannel(c, bson.M{"$addToSet": bson.M{role: body.Id}})
+}
+
+func (h *Handler) AddModerator(c *gin.Context) {
+	h.addToRole(c, "moderators")
+}
+
+func (h *Handler) RemoveModerator(c *gin.Context) {
+	h.updateOwnedChannel(c, bson.M{"$pull": bson.M{"moderators": c.Param("userId")}})
+}
+
+func (h *Handler) AddDJ(c *gin.Context) {
+	h.addToRole(c, "djs")
+}
+
+func (h *Handler) RemoveDJ(c *gin.Context) {
+	h.updateOwnedChannel(c, bson.M{"$pull": bson.M{"djs": c.Param("userId")}})
+}
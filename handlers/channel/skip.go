@@ -0,0 +1,130 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"nbeat-api/models"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// Skip votes for currently playing song in each channel
+	skipVotes = struct {
+		m     sync.Mutex
+		votes map[string]*skipVote
+	}{votes: make(map[string]*skipVote)}
+)
+
+type skipVote struct {
+	songId primitive.ObjectID
+	voters map[string]struct{}
+}
+
+// addSkipVote registers vote of the user for skipping the song and returns number of votes for it.
+// Votes for previous songs are discarded.
+func addSkipVote(channelId string, songId primitive.ObjectID, userId string) int {
+	skipVotes.m.Lock()
+	defer skipVotes.m.Unlock()
+
+	vote, exists := skipVotes.votes[channelId]
+	if !exists || vote.songId != songId {
+		vote = &skipVote{songId: songId, voters: make(map[string]struct{})}
+		skipVotes.votes[channelId] = vote
+	}
+
+	vote.voters[userId] = struct{}{}
+
+	return len(vote.voters)
+}
+
+func clearSkipVotes(channelId string) {
+	skipVotes.m.Lock()
+	delete(skipVotes.votes, channelId)
+	skipVotes.m.Unlock()
+}
+
+func requiredSkipVotes(threshold float64, listeners int) int {
+	return max(1, int(math.Ceil(threshold*float64(listeners))))
+}
+
+func (h *Handler) handleSkipMessage(channelId, userId string) ([]byte, error) {
+	channel, err := h.fetchChannel(context.Background(), channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := h.fetchQueue(channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	current := queue.CurrentSongIndex(queue.Now())
+	if current == -1 {
+		return nil, models.ErrNothingPlaying
+	}
+
+	songId := queue.Songs[current].Id
+
+	if !channel.IsModerator(userId) {
+		votes := addSkipVote(channelId, songId, userId)
+		required := requiredSkipVotes(channel.Settings.GetSkipThreshold(), CountListeners(channelId))
+
+		if votes < required {
+			return json.Marshal(map[string]interface{}{
+				"author": userId,
+				"type":   "skip_vote",
+				"content": map[string]interface{}{
+					"song":     songId,
+					"votes":    votes,
+					"required": required,
+				},
+			})
+		}
+	}
+
+	if err := h.SkipSong(channelId, songId); err != nil {
+		return nil, err
+	}
+
+	upcoming, err := h.FetchUpcomingSongsForChannel(context.Background(), channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"author": userId,
+		"type":   "skip",
+		"content": map[string]interface{}{
+			"song":  songId,
+			"queue": upcoming,
+		},
+	})
+}
+
+// SkipSong ends the song now if it's still playing and moves all songs after it forward
+func (h *Handler) SkipSong(channelId string, songId primitive.ObjectID) error {
+	_, err := h.updateQueue(channelId, func(queue *models.Queue) error {
+		now := queue.Now()
+
+		current := queue.CurrentSongIndex(now)
+		if current == -1 || queue.Songs[current].Id != songId {
+			return models.ErrNothingPlaying
+		}
+
+		queue.Songs[current].SkippedAt = now
+
+		queue.Reschedule(current+1, now)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	clearSkipVotes(channelId)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package channel
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// handlePingMessage replies to the sender with timestamps (ms) of receiving and sending the response,
+// which together with client's own timestamps let it estimate clock offset and latency:
+// offset = ((server_receive_time - client_time) + (server_send_time - client_receive_time)) / 2
+func handlePingMessage(conn *websocket.Conn, messageType int, clientTime string, receivedAt int64) error {
+	content := map[string]interface{}{
+		"client_time":         clientTime,
+		"server_receive_time": receivedAt,
+	}
+	content["server_send_time"] = time.Now().UnixMilli()
+
+	message, err := json.Marshal(map[string]interface{}{
+		"type":    "pong",
+		"content": content,
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendMessage(conn, messageType, message)
+}
+
+// StartSyncWorker periodically sends the current song and its expected position to all listeners,
+// so they can correct drift of their players
+func (h *Handler) StartSyncWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, channelId := range ConnectedChannels() {
+			if err := h.sync(channelId); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+func (h *Handler) sync(channelId string) error {
+	queue, err := h.fetchQueue(channelId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	status := queue.Status()
+	if status.Song == nil {
+		return nil
+	}
+
+	message, err := json.Marshal(map[string]interface{}{
+		"type": "sync",
+		"content": map[string]interface{}{
+			"song":        status.Song.Id,
+			"position":    status.Position,
+			"state":       status.State,
+			"server_time": time.Now().UnixMilli(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return broadcastMessage(websocket.TextMessage, message, channelId)
+}
@@ -0,0 +1,420 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"log"
+	"nbeat-api/db"
+	"nbeat-api/middleware/auth"
+	"nbeat-api/models"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errPlaylistFull = errors.New("playlist is full")
+
+type Handler struct {
+	Db *mongo.Database
+}
+
+// requesterId returns id of the user making the request, or empty string for anonymous requests
+func requesterId(c *gin.Context) string {
+	if claims := auth.ExtractOptionalClaims(c); claims != nil {
+		return claims.Id
+	}
+
+	return ""
+}
+
+// fetchOwnedPlaylist returns playlist from the request's path if it belongs to the user
+func (h *Handler) fetchOwnedPlaylist(c *gin.Context) (models.Playlist, bool) {
+	playlist, err := db.FetchPlaylist(c, h.Db, c.Param("id"))
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusNotFound)
+		return models.Playlist{}, false
+	}
+
+	if playlist.Owner != auth.ExtractClaimsFromContext(c).Id {
+		c.Status(http.StatusForbidden)
+		return models.Playlist{}, false
+	}
+
+	return playlist, true
+}
+
+func (h *Handler) CreatePlaylist(c *gin.Context) {
+	var playlist models.Playlist
+
+	if err := c.BindJSON(&playlist); err != nil {
+		log.Println(err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := playlist.Validate(); err != nil {
+		log.Println(err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	playlist.Id = primitive.NilObjectID
+	playlist.Owner = auth.ExtractClaimsFromContext(c).Id
+	playlist.Songs = []models.Song{}
+
+	res, err := h.Db.Collection("playlist").InsertOne(c, playlist)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	playlist.Id = res.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, playlist)
+}
+
+func (h *Handler) GetPlaylist(c *gin.Context) {
+	playlist, err := db.FetchPlaylist(c, h.Db, c.Param("id"))
+	if err != nil || !playlist.CanView(requesterId(c)) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// FetchUserPlaylists returns public playlists of the user, or all of them if the user asks for their own
+func (h *Handler) FetchUserPlaylists(c *gin.Context) {
+	userId := c.Param("id")
+
+	filter := bson.M{"owner": userId}
+	if requesterId(c) != userId {
+		filter["visibility"] = models.PlaylistPublic
+	}
+
+	opts := options.Find().SetProjection(bson.M{"songs": 0})
+
+	cursor, err := h.Db.Collection("playlist").Find(c, filter, opts)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	playlists := []models.Playlist{}
+	if err := cursor.All(c, &playlists); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, playlists)
+}
+
+func (h *Handler) UpdatePlaylist(c *gin.Context) {
+	var body models.Playlist
+
+	if err := c.BindJSON(&body); err != nil {
+		log.Println(err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := body.Validate(); err != nil {
+		log.Println(err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	playlist, ok := h.fetchOwnedPlaylist(c)
+	if !ok {
+		return
+	}
+
+	update := bson.M{"$set": bson.M{
+		"name":       body.Name,
+		"visibility": body.Visibility,
+	}}
+
+	if _, err := h.Db.Collection("playlist").UpdateByID(c, playlist.Id, update); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) DeletePlaylist(c *gin.Context) {
+	playlist, ok := h.fetchOwnedPlaylist(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.Db.Collection("playlist").DeleteOne(c, bson.M{"_id": playlist.Id}); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddSongs saves songs from the channel into the playlist: the song with the given id from its queue or history,
+// the whole history, or the current song if neither is set
+func (h *Handler) AddSongs(c *gin.Context) {
+	var body struct {
+		ChannelId string `json:"channel_id"`
+		Song      string `json:"song"`
+		History   bool   `json:"history"`
+	}
+
+	if err := c.BindJSON(&body); err != nil {
+		log.Println(err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	playlist, ok := h.fetchOwnedPlaylist(c)
+	if !ok {
+		return
+	}
+
+	channelObjId, err := primitive.ObjectIDFromHex(body.ChannelId)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if exists, err := db.ChannelExists(c, h.Db, channelObjId); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	} else if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var songs []models.Song
+
+	switch {
+	case body.History:
+		songs, err = h.fetchChannelHistory(c, channelObjId)
+	case body.Song != "":
+		var songObjId primitive.ObjectID
+		if songObjId, err = primitive.ObjectIDFromHex(body.Song); err == nil {
+			songs, err = h.fetchChannelSong(c, channelObjId, songObjId)
+		}
+	default:
+		songs, err = h.fetchCurrentSong(c, channelObjId)
+	}
+
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && len(songs) == 0) {
+		c.Status(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.pushSongs(c, playlist, songs); errors.Is(err, errPlaylistFull) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) pushSongs(c context.Context, playlist models.Playlist, songs []models.Song) error {
+	entries := make([]models.Song, 0, len(songs))
+	for _, song := range songs {
+		entries = append(entries, song.NewEntry())
+	}
+
+	// capacity is checked by the update itself, so concurrent requests can't exceed it
+	filter := bson.M{
+		"_id": playlist.Id,
+		"$expr": bson.M{"$lte": []interface{}{
+			bson.M{"$size": bson.M{"$ifNull": []interface{}{"$songs", []interface{}{}}}},
+			models.MaxPlaylistSongs - len(entries),
+		}},
+	}
+	update := bson.M{"$push": bson.M{"songs": bson.M{"$each": entries}}}
+
+	res, err := h.Db.Collection("playlist").UpdateOne(c, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errPlaylistFull
+	}
+
+	return nil
+}
+
+func (h *Handler) fetchCurrentSong(c context.Context, channelObjId primitive.ObjectID) ([]models.Song, error) {
+	var queue models.Queue
+	if err := h.Db.Collection("queue").FindOne(c, bson.M{"channel_id": channelObjId}).Decode(&queue); err != nil {
+		return nil, err
+	}
+
+	status := queue.Status()
+	if status.Song == nil {
+		return nil, nil
+	}
+
+	return []models.Song{*status.Song}, nil
+}
+
+func (h *Handler) fetchChannelSong(c context.Context, channelObjId, songObjId primitive.ObjectID) ([]models.Song, error) {
+	var queue models.Queue
+	err := h.Db.Collection("queue").FindOne(c, bson.M{"channel_id": channelObjId}).Decode(&queue)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if idx := slices.IndexFunc(queue.Songs, func(s models.Song) bool { return s.Id == songObjId }); idx != -1 {
+		return []models.Song{queue.Songs[idx]}, nil
+	}
+
+	var entry models.HistorySong
+	filter := bson.M{"channel_id": channelObjId, "id": songObjId}
+	if err := h.Db.Collection("song_history").FindOne(c, filter).Decode(&entry); err != nil {
+		return nil, err
+	}
+
+	return []models.Song{entry.Song}, nil
+}
+
+// fetchChannelHistory returns distinct songs played in the channel, in order they were first played
+func (h *Handler) fetchChannelHistory(c context.Context, channelObjId primitive.ObjectID) ([]models.Song, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"channel_id": channelObjId}},
+		{"$sort": bson.M{"song_start_time": 1}},
+		{"$group": bson.M{
+			"_id":  "$song_id",
+			"song": bson.M{"$first": "$$ROOT"},
+		}},
+		{"$replaceRoot": bson.M{"newRoot": "$song"}},
+		{"$sort": bson.M{"song_start_time": 1}},
+		{"$limit": models.MaxPlaylistSongs},
+	}
+
+	cursor, err := h.Db.Collection("song_history").Aggregate(c, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.HistorySong
+	if err := cursor.All(c, &entries); err != nil {
+		return nil, err
+	}
+
+	songs := make([]models.Song, 0, len(entries))
+	for _, entry := range entries {
+		songs = append(songs, entry.Song)
+	}
+
+	return songs, nil
+}
+
+func (h *Handler) RemoveSong(c *gin.Context) {
+	songObjId, err := primitive.ObjectIDFromHex(c.Param("songId"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	playlist, ok := h.fetchOwnedPlaylist(c)
+	if !ok {
+		return
+	}
+
+	update := bson.M{"$pull": bson.M{"songs": bson.M{"id": songObjId}}}
+
+	if res, err := h.Db.Collection("playlist").UpdateByID(c, playlist.Id, update); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	} else if res.ModifiedCount == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) MoveSong(c *gin.Context) {
+	songObjId, err := primitive.ObjectIDFromHex(c.Param("songId"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		Position *int `json:"position"`
+	}
+
+	if err := c.BindJSON(&body); err != nil || body.Position == nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	playlist, ok := h.fetchOwnedPlaylist(c)
+	if !ok {
+		return
+	}
+
+	idx := slices.IndexFunc(playlist.Songs, func(s models.Song) bool { return s.Id == songObjId })
+	if idx == -1 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	// the filter makes sure songs weren't changed or reordered in the meantime
+	filter := bson.M{
+		"_id":   playlist.Id,
+		"$expr": bson.M{"$eq": []interface{}{"$songs.id", songIds(playlist.Songs)}},
+	}
+
+	song := playlist.Songs[idx]
+	songs := slices.Delete(playlist.Songs, idx, idx+1)
+	songs = slices.Insert(songs, min(max(*body.Position, 0), len(songs)), song)
+
+	update := bson.M{"$set": bson.M{"songs": songs}}
+
+	if res, err := h.Db.Collection("playlist").UpdateOne(c, filter, update); err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	} else if res.MatchedCount == 0 {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func songIds(songs []models.Song) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(songs))
+	for _, song := range songs {
+		ids = append(ids, song.Id)
+	}
+
+	return ids
+}
@@ -0,0 +1,76 @@
+package upload
+
+import (
+	"errors"
+	"io"
+	"log"
+	"nbeat-api/media"
+	"nbeat-api/middleware/auth"
+	"nbeat-api/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Uploads saves and opens uploaded files, implemented by media.Uploads
+type Uploads interface {
+	Save(owner, fileName string, content io.Reader) (models.Upload, error)
+	Open(id string) (models.Upload, io.ReadSeekCloser, error)
+}
+
+type Handler struct {
+	Uploads Uploads
+	// MaxSize is the maximum size of uploaded file in bytes
+	MaxSize int64
+}
+
+// UploadSong saves audio file from "file" form field, the song can be queued by posting url of StreamSong in the channel
+func (h *Handler) UploadSong(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxSize)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		log.Println(err)
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.Status(http.StatusRequestEntityTooLarge)
+		} else {
+			c.Status(http.StatusBadRequest)
+		}
+		return
+	}
+	defer file.Close()
+
+	userId := auth.ExtractClaimsFromContext(c).Id
+
+	upload, err := h.Uploads.Save(userId, header.Filename, file)
+	if errors.Is(err, media.ErrUnsupportedAudio) {
+		c.Status(http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
+
+// StreamSong serves content of the uploaded file, Range requests are supported
+func (h *Handler) StreamSong(c *gin.Context) {
+	upload, file, err := h.Uploads.Open(c.Param("id"))
+	if errors.Is(err, media.ErrNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Type", upload.ContentType)
+	http.ServeContent(c.Writer, c.Request, upload.FileName, time.UnixMilli(upload.CreatedAt), file)
+}
@@ -0,0 +1,102 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"nbeat-api/media"
+	"nbeat-api/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testContent = "0123456789"
+
+// fakeUploads serves a single file with id "song" from memory
+type fakeUploads struct{}
+
+func (fakeUploads) Save(owner, fileName string, content io.Reader) (models.Upload, error) {
+	return models.Upload{}, errors.New("not implemented")
+}
+
+func (fakeUploads) Open(id string) (models.Upload, io.ReadSeekCloser, error) {
+	if id != "song" {
+		return models.Upload{}, nil, media.ErrNotFound
+	}
+
+	upload := models.Upload{
+		FileName:    "song.mp3",
+		ContentType: "audio/mpeg",
+		Size:        int64(len(testContent)),
+		CreatedAt:   time.Now().UnixMilli(),
+	}
+
+	return upload, nopCloser{bytes.NewReader([]byte(testContent))}, nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestStreamSong(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := &Handler{Uploads: fakeUploads{}}
+	router := gin.New()
+	router.GET("/api/upload/:id", handler.StreamSong)
+
+	tests := []struct {
+		name             string
+		id               string
+		rangeHeader      string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+	}{
+		{"whole file", "song", "", http.StatusOK, testContent, ""},
+		{"range", "song", "bytes=2-5", http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		{"open ended range", "song", "bytes=7-", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"unsatisfiable range", "song", "bytes=20-30", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"unknown upload", "missing", "", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/upload/"+tt.id, nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if got := rec.Header().Get("Content-Range"); got != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantContentRange)
+			}
+
+			if tt.wantStatus == http.StatusOK || tt.wantStatus == http.StatusPartialContent {
+				if got := rec.Body.String(); got != tt.wantBody {
+					t.Errorf("body = %q, want %q", got, tt.wantBody)
+				}
+				if got := rec.Header().Get("Content-Type"); got != "audio/mpeg" {
+					t.Errorf("Content-Type = %q, want %q", got, "audio/mpeg")
+				}
+				if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+					t.Errorf("Accept-Ranges = %q, want %q", got, "bytes")
+				}
+			}
+		})
+	}
+}
@@ -138,9 +138,55 @@ func (h *Handler) FetchFollowedChannelIDs(c *gin.Context) {
 		return
 	}
 
+	user.FollowedChannels, err = h.filterActiveChannels(c, user.FollowedChannels)
+	if err != nil {
+		log.Println(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
+// filterActiveChannels leaves out ids of deleted channels, keeping the order of the rest
+func (h *Handler) filterActiveChannels(c context.Context, channelIds []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if len(channelIds) == 0 {
+		return channelIds, nil
+	}
+
+	filter := bson.M{
+		"_id":        bson.M{"$in": channelIds},
+		"deleted_at": bson.M{"$exists": false},
+	}
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+
+	cursor, err := h.Db.Collection("channel").Find(c, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(c, &channels); err != nil {
+		return nil, err
+	}
+
+	active := make(map[primitive.ObjectID]struct{}, len(channels))
+	for _, channel := range channels {
+		active[channel.Id] = struct{}{}
+	}
+
+	filtered := make([]primitive.ObjectID, 0, len(active))
+	for _, channelId := range channelIds {
+		if _, exists := active[channelId]; exists {
+			filtered = append(filtered, channelId)
+		}
+	}
+
+	return filtered, nil
+}
+
 func (h *Handler) FetchFollowedChannelsData(c *gin.Context) {
 	userId := c.Param("id")
 
@@ -154,6 +200,7 @@ func (h *Handler) FetchFollowedChannelsData(c *gin.Context) {
 			"as":           "channels",
 		}},
 		{"$unwind": "$channels"},
+		{"$match": bson.M{"channels.deleted_at": bson.M{"$exists": false}}},
 		{"$lookup": bson.M{
 			"from":         "queue",
 			"localField":   "channels._id",
@@ -166,12 +213,25 @@ func (h *Handler) FetchFollowedChannelsData(c *gin.Context) {
 				"$filter": bson.M{
 					"input": "$queueInfo.songs",
 					"as":    "song",
-					"cond":  bson.M{"$lt": []interface{}{"$$song.song_start_time", currentTime}},
+					"cond":  bson.M{"$lt": []interface{}{"$$song.song_start_time", models.QueueClockExpr("$queueInfo.playback", currentTime)}},
 				},
 			},
 		}},
+		{"$lookup": bson.M{
+			"from": "song_history",
+			"let":  bson.M{"channel_id": "$channels._id"},
+			"pipeline": []bson.M{
+				{"$match": bson.M{"$expr": bson.M{"$eq": []interface{}{"$channel_id", "$$channel_id"}}}},
+				{"$sort": bson.M{"song_start_time": -1}},
+				{"$limit": 1},
+			},
+			"as": "lastArchivedSong",
+		}},
 		{"$set": bson.M{
-			"channels.lastPlayedSong": bson.M{"$arrayElemAt": []interface{}{"$queueInfo.songs", -1}},
+			"channels.lastPlayedSong": bson.M{"$ifNull": []interface{}{
+				bson.M{"$arrayElemAt": []interface{}{"$queueInfo.songs", -1}},
+				bson.M{"$arrayElemAt": []interface{}{"$lastArchivedSong", 0}},
+			}},
 		}},
 		{"$group": bson.M{
 			"_id":      "$_id",
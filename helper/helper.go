@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
-	"strings"
+	"strconv"
 	"time"
 )
 
@@ -25,6 +25,55 @@ func MatchSongUrl(url string) string {
 	return ""
 }
 
+// MatchSongOffsets returns start and end offsets (s) from "t", "start" and "end" parameters of the url,
+// "t" can be given in seconds or as e.g. 1h2m3s
+func MatchSongOffsets(url string) (float64, float64) {
+	paramRegex := regexp.MustCompile(`[?&#](t|start|end)=([0-9hms.]+)`)
+
+	var start, end float64
+	for _, match := range paramRegex.FindAllStringSubmatch(url, -1) {
+		offset := parseOffset(match[2])
+
+		if match[1] == "end" {
+			end = offset
+		} else {
+			start = offset
+		}
+	}
+
+	return start, end
+}
+
+func parseOffset(offset string) float64 {
+	if seconds, err := strconv.ParseFloat(offset, 64); err == nil {
+		return seconds
+	}
+
+	if duration, err := time.ParseDuration(offset); err == nil {
+		return duration.Seconds()
+	}
+
+	return 0
+}
+
+// MatchPlaylistUrl returns id of the playlist from "list" parameter of a YouTube url. Urls of a video
+// opened from a playlist or a mix (e.g. watch?v=...&list=RD...) aren't playlist urls, the video is played alone
+func MatchPlaylistUrl(url string) string {
+	urlRegex := regexp.MustCompile(`(?:youtube\.com|youtu\.be)\/\S*?[?&]list=([a-zA-Z0-9_-]+)`)
+	playlistPageRegex := regexp.MustCompile(`youtube\.com\/playlist\?`)
+
+	match := urlRegex.FindStringSubmatch(url)
+	if match == nil || len(match) < 2 {
+		return ""
+	}
+
+	if MatchSongUrl(url) != "" && !playlistPageRegex.MatchString(url) {
+		return ""
+	}
+
+	return match[1]
+}
+
 func GetEnv(name, fallback string) string {
 	if val, exists := os.LookupEnv(name); exists {
 		return val
@@ -33,6 +82,20 @@ func GetEnv(name, fallback string) string {
 	return fallback
 }
 
+func GetEnvDuration(name string, fallback time.Duration) time.Duration {
+	val, exists := os.LookupEnv(name)
+	if !exists {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(val)
+	if err != nil {
+		return fallback
+	}
+
+	return duration
+}
+
 func MatchBearerToken(authHeader string) string {
 	pattern := `^Bearer\s+([a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+)$`
 	re := regexp.MustCompile(pattern)
@@ -46,7 +109,8 @@ func MatchBearerToken(authHeader string) string {
 }
 
 func ParseISODuration(isoDuration string) (time.Duration, error) {
-	re := regexp.MustCompile(`P(?:(\d+)D)?T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?`)
+	// time part is optional, live streams have duration P0D
+	re := regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
 	matches := re.FindStringSubmatch(isoDuration)
 
 	if len(matches) == 0 {
@@ -59,23 +123,21 @@ func ParseISODuration(isoDuration string) (time.Duration, error) {
 	// 2: Hours
 	// 3: Minutes
 	// 4: Seconds
-	var durationStrBuilder strings.Builder
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var duration time.Duration
 	for i, match := range matches[1:] {
 		if match == "" {
 			continue
 		}
-		switch i {
-		case 0:
-			durationStrBuilder.WriteString(match + "h24m")
-		case 1:
-			durationStrBuilder.WriteString(match + "h")
-		case 2:
-			durationStrBuilder.WriteString(match + "m")
-		case 3:
-			durationStrBuilder.WriteString(match + "s")
+
+		value, err := strconv.Atoi(match)
+		if err != nil {
+			return 0, err
 		}
+
+		duration += time.Duration(value) * units[i]
 	}
 
-	durationStr := durationStrBuilder.String()
-	return time.ParseDuration(durationStr)
+	return duration, nil
 }
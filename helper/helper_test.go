@@ -0,0 +1,27 @@
+package helper
+
+import "testing"
+
+func TestMatchPlaylistUrl(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.youtube.com/playlist?list=PLFgquLnL59alCl_2TQvOiD5Vgm1hCaGSI", "PLFgquLnL59alCl_2TQvOiD5Vgm1hCaGSI"},
+		{"https://youtube.com/playlist?si=abc&list=PL123", "PL123"},
+		{"https://www.youtube.com/watch?list=PL123", "PL123"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=RDdQw4w9WgXcQ", ""},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL123&index=2", ""},
+		{"https://youtu.be/dQw4w9WgXcQ?list=PL123", ""},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", ""},
+		{"https://example.com/playlist?list=PL123", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := MatchPlaylistUrl(tt.url); got != tt.want {
+				t.Errorf("MatchPlaylistUrl(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
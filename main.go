@@ -4,9 +4,14 @@ import (
 	"context"
 	"nbeat-api/db"
 	"nbeat-api/handlers/channel"
+	"nbeat-api/handlers/playlist"
+	"nbeat-api/handlers/upload"
 	"nbeat-api/handlers/user"
+	"nbeat-api/helper"
+	"nbeat-api/media"
 	"nbeat-api/middleware/auth"
 	"nbeat-api/middleware/cors"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -26,20 +31,66 @@ func main() {
 		}
 	}()
 
-	db := mongoClient.Database("nbeat")
+	database := mongoClient.Database("nbeat")
 
-	userHandler := user.Handler{Db: db}
-	channelHandler := channel.Handler{Db: db}
+	if err := db.CreateIndexes(database); err != nil {
+		panic(err)
+	}
+
+	userHandler := user.Handler{Db: database}
+	playlistHandler := playlist.Handler{Db: database}
+	youtube := media.NewYouTube(helper.GetEnv("YOUTUBE_API_KEY", ""))
+	youtube.BaseUrl = helper.GetEnv("YOUTUBE_API_URL", media.YouTubeBaseUrl)
+	youtube.Region = helper.GetEnv("YOUTUBE_REGION", "")
+	youtube.Client.Timeout = helper.GetEnvDuration("YOUTUBE_API_TIMEOUT", youtube.Client.Timeout)
+
+	uploads := &media.Uploads{
+		Db:      database,
+		Storage: &media.DiskStorage{Dir: helper.GetEnv("UPLOAD_DIR", "uploads")},
+	}
+
+	uploadHandler := upload.Handler{
+		Uploads: uploads,
+		MaxSize: 50 << 20,
+	}
+
+	catalog := &media.Catalog{
+		Db:  database,
+		TTL: helper.GetEnvDuration("SONG_CATALOG_TTL", 24*time.Hour),
+	}
+
+	channelHandler := channel.Handler{
+		Db: database,
+		Providers: media.NewRegistry(
+			catalog.Wrap(youtube),
+			uploads,
+		),
+		Catalog:       catalog,
+		Search:        media.NewSearchCache(helper.GetEnvDuration("SONG_SEARCH_TTL", 5*time.Minute)),
+		RestoreWindow: helper.GetEnvDuration("CHANNEL_RESTORE_WINDOW", 7*24*time.Hour),
+	}
+
+	go channelHandler.StartPurgeWorker(time.Hour)
+	go channelHandler.StartAutoplayWorker(2 * time.Second)
+	go channelHandler.StartArchiveWorker(helper.GetEnvDuration("ARCHIVE_INTERVAL", time.Minute))
+	go channelHandler.StartSyncWorker(helper.GetEnvDuration("SYNC_INTERVAL", 10*time.Second))
 
 	router.Use(cors.Middleware())
 
 	router.POST("/api/login", userHandler.Login)
 	router.POST("/api/register", userHandler.Register)
 	router.GET("/api/channel/:id", channelHandler.GetChannel)
+	router.GET("/api/channel/:id/history", channelHandler.GetHistory)
+	router.GET("/api/channel/:id/top-songs", channelHandler.GetTopSongs)
+	router.GET("/api/channel/:id/top-djs", channelHandler.GetTopDJs)
+	router.GET("/api/song/search", channelHandler.SearchSongs)
 	router.GET("/api/song/:id", channelHandler.GetSongData)
+	router.GET("/api/upload/:id", uploadHandler.StreamSong)
 	router.GET("/ws/channel/:id", channelHandler.Channel)
 	router.GET("/api/user/:id/followedChannelIds", userHandler.FetchFollowedChannelIDs)
 	router.GET("/api/user/:id/followedChannels", userHandler.FetchFollowedChannelsData)
+	router.GET("/api/user/:id/playlists", playlistHandler.FetchUserPlaylists)
+	router.GET("/api/playlist/:id", playlistHandler.GetPlaylist)
 
 	authorized := router.Group("/")
 	authorized.Use(auth.Auth())
@@ -47,6 +98,31 @@ func main() {
 		authorized.POST("/api/channel", channelHandler.CreateChannel)
 		authorized.POST("/api/channel/:id/subscribe", channelHandler.FollowChannel)
 		authorized.DELETE("/api/channel/:id", channelHandler.DeleteChannel)
+		authorized.POST("/api/channel/:id/restore", channelHandler.RestoreChannel)
+		authorized.PUT("/api/channel/:id/settings", channelHandler.UpdateSettings)
+		authorized.POST("/api/channel/:id/moderators", channelHandler.AddModerator)
+		authorized.DELETE("/api/channel/:id/moderators/:userId", channelHandler.RemoveModerator)
+		authorized.POST("/api/channel/:id/djs", channelHandler.AddDJ)
+		authorized.DELETE("/api/channel/:id/djs/:userId", channelHandler.RemoveDJ)
+		authorized.DELETE("/api/channel/:id/queue/:songId", channelHandler.RemoveFromQueue)
+		authorized.POST("/api/channel/:id/queue/:songId/move", channelHandler.MoveInQueue)
+		authorized.POST("/api/channel/:id/queue/:songId/next", channelHandler.PlayNext)
+		authorized.POST("/api/channel/:id/playlist/:playlistId", channelHandler.EnqueuePlaylist)
+		authorized.POST("/api/channel/:id/songs/:songId/like", channelHandler.PostLike)
+		authorized.GET("/api/channel/:id/requests", channelHandler.GetRequests)
+		authorized.POST("/api/channel/:id/requests/:requestId/approve", channelHandler.PostApproveRequest)
+		authorized.POST("/api/channel/:id/requests/:requestId/reject", channelHandler.PostRejectRequest)
+		authorized.DELETE("/api/channel/:id/songs/:songId/like", channelHandler.DeleteLike)
+		authorized.GET("/api/channel/:id/blocklist", channelHandler.GetBlocklist)
+		authorized.POST("/api/channel/:id/blocklist/:type", channelHandler.AddToBlocklist)
+		authorized.DELETE("/api/channel/:id/blocklist/:type/:value", channelHandler.RemoveFromBlocklist)
+		authorized.POST("/api/upload", uploadHandler.UploadSong)
+		authorized.POST("/api/playlist", playlistHandler.CreatePlaylist)
+		authorized.PUT("/api/playlist/:id", playlistHandler.UpdatePlaylist)
+		authorized.DELETE("/api/playlist/:id", playlistHandler.DeletePlaylist)
+		authorized.POST("/api/playlist/:id/songs", playlistHandler.AddSongs)
+		authorized.DELETE("/api/playlist/:id/songs/:songId", playlistHandler.RemoveSong)
+		authorized.POST("/api/playlist/:id/songs/:songId/move", playlistHandler.MoveSong)
 	}
 
 	router.Run("0.0.0.0:8080")
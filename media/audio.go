@@ -0,0 +1,486 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+var ErrUnsupportedAudio = errors.New("unsupported audio format")
+
+// AudioInfo holds metadata read from an audio file, Duration is in seconds
+type AudioInfo struct {
+	ContentType string
+	Duration    float64
+	Title       string
+	Artist      string
+}
+
+// ReadAudioInfo reads duration and tags of MP3, WAV or FLAC file
+func ReadAudioInfo(r io.ReadSeeker) (AudioInfo, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return AudioInfo{}, ErrUnsupportedAudio
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return AudioInfo{}, err
+	}
+
+	var info AudioInfo
+	var err error
+
+	switch {
+	case string(magic) == "RIFF":
+		info, err = readWavInfo(r)
+	case string(magic) == "fLaC":
+		info, err = readFlacInfo(r)
+	case isMp3Header(magic):
+		info, err = readMp3Info(r)
+	default:
+		return AudioInfo{}, ErrUnsupportedAudio
+	}
+
+	if err != nil {
+		return AudioInfo{}, err
+	}
+
+	if info.Duration <= 0 {
+		return AudioInfo{}, ErrUnsupportedAudio
+	}
+
+	return info, nil
+}
+
+func readWavInfo(r io.ReadSeeker) (AudioInfo, error) {
+	info := AudioInfo{ContentType: "audio/wav"}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[8:12]) != "WAVE" {
+		return AudioInfo{}, ErrUnsupportedAudio
+	}
+
+	var byteRate, dataSize uint32
+	for {
+		chunk := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			break
+		}
+
+		id, size := string(chunk[:4]), binary.LittleEndian.Uint32(chunk[4:])
+		// chunks are padded to even size
+		next := int64(size) + int64(size%2)
+
+		switch id {
+		case "fmt ":
+			format := make([]byte, 12)
+			if size < 12 {
+				return AudioInfo{}, ErrUnsupportedAudio
+			}
+			if _, err := io.ReadFull(r, format); err != nil {
+				return AudioInfo{}, ErrUnsupportedAudio
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:12])
+			next -= 12
+		case "data":
+			dataSize = size
+		case "LIST":
+			if size <= 1<<20 {
+				list := make([]byte, size)
+				if _, err := io.ReadFull(r, list); err != nil {
+					return AudioInfo{}, ErrUnsupportedAudio
+				}
+				readWavListInfo(list, &info)
+				next -= int64(size)
+			}
+		}
+
+		if _, err := r.Seek(next, io.SeekCurrent); err != nil {
+			break
+		}
+	}
+
+	if byteRate == 0 {
+		return AudioInfo{}, ErrUnsupportedAudio
+	}
+
+	info.Duration = float64(dataSize) / float64(byteRate)
+
+	return info, nil
+}
+
+func readWavListInfo(list []byte, info *AudioInfo) {
+	if len(list) < 4 || string(list[:4]) != "INFO" {
+		return
+	}
+
+	for i := 4; i+8 <= len(list); {
+		id, size := string(list[i:i+4]), int(binary.LittleEndian.Uint32(list[i+4:i+8]))
+		i += 8
+		if size > len(list)-i {
+			return
+		}
+
+		value := strings.TrimRight(string(list[i:i+size]), "\x00")
+		switch id {
+		case "INAM":
+			info.Title = value
+		case "IART":
+			info.Artist = value
+		}
+
+		i += size + size%2
+	}
+}
+
+func readFlacInfo(r io.ReadSeeker) (AudioInfo, error) {
+	info := AudioInfo{ContentType: "audio/flac"}
+
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return AudioInfo{}, err
+	}
+
+	for last := false; !last; {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return AudioInfo{}, ErrUnsupportedAudio
+		}
+
+		last = header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch blockType {
+		case 0: // STREAMINFO
+			block := make([]byte, size)
+			if size < 18 {
+				return AudioInfo{}, ErrUnsupportedAudio
+			}
+			if _, err := io.ReadFull(r, block); err != nil {
+				return AudioInfo{}, ErrUnsupportedAudio
+			}
+
+			sampleRate := uint64(block[10])<<12 | uint64(block[11])<<4 | uint64(block[12])>>4
+			totalSamples := uint64(block[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(block[14:18]))
+			if sampleRate != 0 {
+				info.Duration = float64(totalSamples) / float64(sampleRate)
+			}
+		case 4: // VORBIS_COMMENT
+			block := make([]byte, size)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return AudioInfo{}, ErrUnsupportedAudio
+			}
+			readVorbisComments(block, &info)
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return AudioInfo{}, err
+			}
+		}
+	}
+
+	return info, nil
+}
+
+func readVorbisComments(block []byte, info *AudioInfo) {
+	readString := func() (string, bool) {
+		if len(block) < 4 {
+			return "", false
+		}
+
+		size := binary.LittleEndian.Uint32(block)
+		block = block[4:]
+		if uint64(size) > uint64(len(block)) {
+			return "", false
+		}
+
+		value := string(block[:size])
+		block = block[size:]
+
+		return value, true
+	}
+
+	// vendor string
+	if _, ok := readString(); !ok || len(block) < 4 {
+		return
+	}
+
+	count := binary.LittleEndian.Uint32(block)
+	block = block[4:]
+
+	for i := uint32(0); i < count; i++ {
+		comment, ok := readString()
+		if !ok {
+			return
+		}
+
+		key, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			info.Title = value
+		case "ARTIST":
+			info.Artist = value
+		}
+	}
+}
+
+var (
+	// mp3Bitrates holds bitrates (kbps) of layer III frames for MPEG-1 and MPEG-2/2.5
+	mp3Bitrates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	// mp3SampleRates holds sample rates for MPEG-1, MPEG-2 and MPEG-2.5
+	mp3SampleRates = [3][4]int{
+		{44100, 48000, 32000, 0},
+		{22050, 24000, 16000, 0},
+		{11025, 12000, 8000, 0},
+	}
+)
+
+// mp3SearchLimit is the number of bytes after tags searched for the first frame
+const mp3SearchLimit = 64 << 10
+
+func readMp3Info(r io.ReadSeeker) (AudioInfo, error) {
+	info := AudioInfo{ContentType: "audio/mpeg"}
+
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return AudioInfo{}, err
+	}
+
+	audioStart, err := readId3v2(r, fileSize, &info)
+	if err != nil {
+		return AudioInfo{}, err
+	}
+
+	audioEnd := readId3v1(r, fileSize, &info)
+
+	if _, err := r.Seek(audioStart, io.SeekStart); err != nil {
+		return AudioInfo{}, err
+	}
+
+	data := make([]byte, mp3SearchLimit)
+	n, _ := io.ReadFull(r, data)
+	data = data[:n]
+
+	for i := 0; i+4 <= len(data); i++ {
+		if data[i] != 0xff || data[i+1]&0xe0 != 0xe0 {
+			continue
+		}
+
+		header := binary.BigEndian.Uint32(data[i:])
+		version := (header >> 19) & 3 // 0: MPEG-2.5, 2: MPEG-2, 3: MPEG-1
+		layer := (header >> 17) & 3   // 1: layer III
+		bitrateIndex := (header >> 12) & 0xf
+		sampleRateIndex := (header >> 10) & 3
+		mono := (header>>6)&3 == 3
+
+		if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+			continue
+		}
+
+		versionIndex := map[uint32]int{3: 0, 2: 1, 0: 2}[version]
+		sampleRate := mp3SampleRates[versionIndex][sampleRateIndex]
+		bitrate := mp3Bitrates[min(versionIndex, 1)][bitrateIndex] * 1000
+
+		samplesPerFrame, sideInfo := 1152, 32
+		if versionIndex != 0 {
+			samplesPerFrame, sideInfo = 576, 17
+			if mono {
+				sideInfo = 9
+			}
+		} else if mono {
+			sideInfo = 17
+		}
+
+		// VBR files keep the number of frames in Xing (Info) or VBRI header of the first frame
+		if frames := mp3FrameCount(data[i:], 4+sideInfo); frames != 0 {
+			info.Duration = float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+		} else {
+			info.Duration = float64(audioEnd-audioStart-int64(i)) * 8 / float64(bitrate)
+		}
+
+		return info, nil
+	}
+
+	return AudioInfo{}, ErrUnsupportedAudio
+}
+
+func mp3FrameCount(frame []byte, xingOffset int) uint32 {
+	if len(frame) >= xingOffset+12 {
+		tag := string(frame[xingOffset : xingOffset+4])
+		flags := binary.BigEndian.Uint32(frame[xingOffset+4:])
+		if (tag == "Xing" || tag == "Info") && flags&1 != 0 {
+			return binary.BigEndian.Uint32(frame[xingOffset+8:])
+		}
+	}
+
+	const vbriOffset = 36
+	if len(frame) >= vbriOffset+18 && string(frame[vbriOffset:vbriOffset+4]) == "VBRI" {
+		return binary.BigEndian.Uint32(frame[vbriOffset+14:])
+	}
+
+	return 0
+}
+
+// readId3v2 reads title and artist from ID3v2 tag and returns the offset at which audio starts
+func readId3v2(r io.ReadSeeker, fileSize int64, info *AudioInfo) (int64, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	version, flags := header[3], header[5]
+	size := int64(syncsafe(header[6:10]))
+
+	audioStart := 10 + size
+	if flags&0x10 != 0 {
+		// footer
+		audioStart += 10
+	}
+
+	if audioStart > fileSize {
+		return 0, ErrUnsupportedAudio
+	}
+
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return 0, ErrUnsupportedAudio
+	}
+
+	if flags&0x40 != 0 && version >= 3 && len(tag) >= 4 {
+		// extended header, in ID3v2.3 its size doesn't include the size field
+		extendedSize := int(binary.BigEndian.Uint32(tag))
+		if version == 3 {
+			extendedSize += 4
+		} else {
+			extendedSize = int(syncsafe(tag[:4]))
+		}
+		tag = tag[min(extendedSize, len(tag)):]
+	}
+
+	idSize, headerSize := 4, 10
+	titleId, artistId := "TIT2", "TPE1"
+	if version == 2 {
+		idSize, headerSize = 3, 6
+		titleId, artistId = "TT2", "TP1"
+	}
+
+	for len(tag) >= headerSize && tag[0] != 0 {
+		id := string(tag[:idSize])
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(tag[4:8]))
+		default:
+			frameSize = int(syncsafe(tag[4:8]))
+		}
+
+		tag = tag[headerSize:]
+		if frameSize > len(tag) || frameSize < 0 {
+			break
+		}
+
+		switch id {
+		case titleId:
+			info.Title = decodeId3Text(tag[:frameSize])
+		case artistId:
+			info.Artist = decodeId3Text(tag[:frameSize])
+		}
+
+		tag = tag[frameSize:]
+	}
+
+	return audioStart, nil
+}
+
+// readId3v1 reads title and artist from ID3v1 tag unless they are already set, and returns the offset at which audio ends
+func readId3v1(r io.ReadSeeker, fileSize int64, info *AudioInfo) int64 {
+	if fileSize < 128 {
+		return fileSize
+	}
+
+	if _, err := r.Seek(fileSize-128, io.SeekStart); err != nil {
+		return fileSize
+	}
+
+	tag := make([]byte, 128)
+	if _, err := io.ReadFull(r, tag); err != nil || string(tag[:3]) != "TAG" {
+		return fileSize
+	}
+
+	trim := func(value []byte) string {
+		return strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+	}
+
+	if info.Title == "" {
+		info.Title = trim(tag[3:33])
+	}
+	if info.Artist == "" {
+		info.Artist = trim(tag[33:63])
+	}
+
+	return fileSize - 128
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7f)<<21 | uint32(b[1]&0x7f)<<14 | uint32(b[2]&0x7f)<<7 | uint32(b[3]&0x7f)
+}
+
+// decodeId3Text decodes text frame, the first byte tells the encoding
+func decodeId3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+
+	encoding, text := frame[0], frame[1:]
+
+	var value string
+	switch encoding {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(text) >= 2 && text[0] == 0xff && text[1] == 0xfe {
+			order, text = binary.LittleEndian, text[2:]
+		} else if len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff {
+			text = text[2:]
+		}
+
+		units := make([]uint16, 0, len(text)/2)
+		for i := 0; i+1 < len(text); i += 2 {
+			units = append(units, order.Uint16(text[i:]))
+		}
+		value = string(utf16.Decode(units))
+	case 3: // UTF-8
+		value = string(text)
+	default: // ISO-8859-1
+		runes := make([]rune, 0, len(text))
+		for _, b := range text {
+			runes = append(runes, rune(b))
+		}
+		value = string(runes)
+	}
+
+	// multiple values are separated with null characters, only the first one is used
+	value, _, _ = strings.Cut(value, "\x00")
+
+	return strings.TrimSpace(value)
+}
+
+// isMp3Header tells whether the file starts with ID3v2 tag or MPEG frame
+func isMp3Header(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("ID3")) || len(header) >= 2 && header[0] == 0xff && header[1]&0xe0 == 0xe0
+}
@@ -0,0 +1,53 @@
+package media
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadAudioInfo(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    AudioInfo
+		wantErr error
+	}{
+		{"tone.wav", AudioInfo{ContentType: "audio/wav", Duration: 2, Title: "Tone", Artist: "nbeat"}, nil},
+		{"tone.flac", AudioInfo{ContentType: "audio/flac", Duration: 3, Title: "Tone", Artist: "nbeat"}, nil},
+		{"cbr.mp3", AudioInfo{ContentType: "audio/mpeg", Duration: 0.25, Title: "Tone", Artist: "nbeat"}, nil},
+		{"vbr.mp3", AudioInfo{ContentType: "audio/mpeg", Duration: 100 * 1152 / 44100.0}, nil},
+		{"truncated.wav", AudioInfo{}, ErrUnsupportedAudio},
+		{"malformed.wav", AudioInfo{}, ErrUnsupportedAudio},
+		{"truncated.flac", AudioInfo{}, ErrUnsupportedAudio},
+		{"malformed.flac", AudioInfo{}, ErrUnsupportedAudio},
+		{"truncated.mp3", AudioInfo{}, ErrUnsupportedAudio},
+		{"malformed.mp3", AudioInfo{}, ErrUnsupportedAudio},
+		{"text.txt", AudioInfo{}, ErrUnsupportedAudio},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			got, err := ReadAudioInfo(file)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadAudioInfo() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if math.Abs(got.Duration-tt.want.Duration) > 1e-6 {
+				t.Errorf("Duration = %v, want %v", got.Duration, tt.want.Duration)
+			}
+
+			got.Duration = tt.want.Duration
+			if got != tt.want {
+				t.Errorf("ReadAudioInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
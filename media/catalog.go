@@ -0,0 +1,260 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"log"
+	"nbeat-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/singleflight"
+)
+
+// Catalog keeps metadata of songs fetched from providers in song_catalog collection,
+// so providers are asked again only after TTL passes
+type Catalog struct {
+	Db  *mongo.Database
+	TTL time.Duration
+
+	// concurrent fetches of the same song share a single request to the provider
+	group singleflight.Group
+}
+
+// catalogVersion is increased when songs get new metadata (e.g. restrictions, parts of the title, uploader),
+// entries stored with an older version are fetched again regardless of TTL
+const catalogVersion = 4
+
+type catalogEntry struct {
+	Provider  string      `bson:"provider"`
+	SongId    string      `bson:"song_id"`
+	Song      models.Song `bson:"song"`
+	FetchedAt int64       `bson:"fetched_at"`
+	Version   int         `bson:"version"`
+	PlayCount int64       `bson:"play_count"`
+}
+
+// cachedProvider looks songs up in the catalog before asking the wrapped provider
+type cachedProvider struct {
+	MediaProvider
+	catalog *Catalog
+}
+
+func (p *cachedProvider) FetchSong(id string) (models.Song, error) {
+	return p.catalog.fetchSong(p.MediaProvider, id)
+}
+
+// Search finds songs with the wrapped provider and stores them in the catalog,
+// so the song picked from results doesn't have to be fetched again
+func (p *cachedProvider) Search(query string, limit int) ([]models.Song, error) {
+	searcher, ok := p.MediaProvider.(Searcher)
+	if !ok {
+		return nil, ErrSearchUnsupported
+	}
+
+	songs, err := searcher.Search(query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.catalog.store(p.Name(), songs); err != nil {
+		log.Println(err)
+	}
+
+	return songs, nil
+}
+
+// FetchSongs looks all songs up in the catalog at once, the ones missing or outdated are fetched
+// with the wrapped provider in batches if it supports them
+func (p *cachedProvider) FetchSongs(ids []string) ([]models.Song, error) {
+	return p.catalog.fetchSongs(p.MediaProvider, ids)
+}
+
+func (p *cachedProvider) MatchPlaylistUrl(url string) string {
+	playlistProvider, ok := p.MediaProvider.(PlaylistProvider)
+	if !ok {
+		return ""
+	}
+
+	return playlistProvider.MatchPlaylistUrl(url)
+}
+
+// FetchPlaylist fetches songs of the playlist with the wrapped provider and stores them in the catalog
+func (p *cachedProvider) FetchPlaylist(id string) ([]models.Song, error) {
+	playlistProvider, ok := p.MediaProvider.(PlaylistProvider)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	songs, err := playlistProvider.FetchPlaylist(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.catalog.store(p.Name(), songs); err != nil {
+		log.Println(err)
+	}
+
+	return songs, nil
+}
+
+// Wrap returns provider which uses the catalog as cache for the given one
+func (c *Catalog) Wrap(provider MediaProvider) MediaProvider {
+	return &cachedProvider{MediaProvider: provider, catalog: c}
+}
+
+// catalogSong returns metadata of the song without data about its place in a queue
+func catalogSong(song models.Song) models.Song {
+	song = song.NewEntry()
+	song.Id = primitive.NilObjectID
+	song.StartOffset = 0
+	song.EndOffset = 0
+
+	return song
+}
+
+func (c *Catalog) collection() *mongo.Collection {
+	return c.Db.Collection("song_catalog")
+}
+
+func (c *Catalog) fetchSong(provider MediaProvider, id string) (models.Song, error) {
+	filter := bson.M{"provider": provider.Name(), "song_id": id}
+
+	var entry catalogEntry
+	err := c.collection().FindOne(context.Background(), filter).Decode(&entry)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Song{}, err
+	}
+
+	if err == nil && entry.Version == catalogVersion && time.Since(time.UnixMilli(entry.FetchedAt)) < c.TTL {
+		return entry.Song, nil
+	}
+
+	song, err, _ := c.group.Do(provider.Name()+":"+id, func() (interface{}, error) {
+		song, err := provider.FetchSong(id)
+		if err != nil {
+			return models.Song{}, err
+		}
+
+		song = catalogSong(song)
+		if err := c.store(provider.Name(), []models.Song{song}); err != nil {
+			return models.Song{}, err
+		}
+
+		return song, nil
+	})
+
+	return song.(models.Song), err
+}
+
+func (c *Catalog) fetchSongs(provider MediaProvider, ids []string) ([]models.Song, error) {
+	filter := bson.M{"provider": provider.Name(), "song_id": bson.M{"$in": ids}}
+
+	cursor, err := c.collection().Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []catalogEntry{}
+	if err := cursor.All(context.Background(), &entries); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]models.Song, len(ids))
+	for _, entry := range entries {
+		if entry.Version == catalogVersion && time.Since(time.UnixMilli(entry.FetchedAt)) < c.TTL {
+			found[entry.SongId] = entry.Song
+		}
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if _, exists := found[id]; !exists {
+			missing = append(missing, id)
+		}
+	}
+
+	if batchProvider, ok := provider.(BatchProvider); ok && len(missing) > 0 {
+		fetched, err := batchProvider.FetchSongs(missing)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.store(provider.Name(), fetched); err != nil {
+			log.Println(err)
+		}
+
+		for _, song := range fetched {
+			found[song.SongId] = catalogSong(song)
+		}
+	} else {
+		for _, id := range missing {
+			song, err := c.fetchSong(provider, id)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			found[id] = song
+		}
+	}
+
+	songs := make([]models.Song, 0, len(ids))
+	for _, id := range ids {
+		if song, exists := found[id]; exists {
+			songs = append(songs, song)
+		}
+	}
+
+	return songs, nil
+}
+
+// store saves fetched metadata of the songs
+func (c *Catalog) store(provider string, songs []models.Song) error {
+	writes := make([]mongo.WriteModel, 0, len(songs))
+	for _, song := range songs {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"provider": provider, "song_id": song.SongId}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"song":       catalogSong(song),
+				"fetched_at": time.Now().UnixMilli(),
+				"version":    catalogVersion,
+			}}).
+			SetUpsert(true),
+		)
+	}
+
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, err := c.collection().BulkWrite(context.Background(), writes)
+
+	return err
+}
+
+// CountPlays increments global play counts of the songs, called when they finish playing
+func (c *Catalog) CountPlays(songs []models.Song) error {
+	writes := make([]mongo.WriteModel, 0, len(songs))
+	for _, song := range songs {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"provider": song.Provider, "song_id": song.SongId}).
+			SetUpdate(bson.M{
+				"$inc":         bson.M{"play_count": 1},
+				"$setOnInsert": bson.M{"song": catalogSong(song)},
+			}).
+			SetUpsert(true),
+		)
+	}
+
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, err := c.collection().BulkWrite(context.Background(), writes)
+
+	return err
+}
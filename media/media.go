@@ -0,0 +1,81 @@
+package media
+
+import (
+	"errors"
+	"nbeat-api/models"
+)
+
+var (
+	ErrNotFound      = errors.New("media not found")
+	ErrQuotaExceeded = errors.New("provider quota exceeded")
+	ErrInvalidKey    = errors.New("invalid provider API key")
+)
+
+// MediaProvider resolves songs from a media platform (YouTube, SoundCloud, direct audio urls...)
+type MediaProvider interface {
+	// Name identifies the provider, it's stored in songs so clients know which player to use
+	Name() string
+	// MatchUrl returns id of the media the url points to, or empty string if the provider doesn't support the url
+	MatchUrl(url string) string
+	// FetchSong returns the song with normalized metadata of the media with the given id
+	FetchSong(id string) (models.Song, error)
+}
+
+// BatchProvider is implemented by providers which can fetch many songs with fewer requests than one per song
+type BatchProvider interface {
+	// FetchSongs returns songs with the given ids in the same order, media which can't be found is left out
+	FetchSongs(ids []string) ([]models.Song, error)
+}
+
+// PlaylistProvider is implemented by providers which can expand playlist urls into songs
+type PlaylistProvider interface {
+	// MatchPlaylistUrl returns id of the playlist the url points to, or empty string if it's not a playlist url
+	MatchPlaylistUrl(url string) string
+	// FetchPlaylist returns songs of the playlist in order
+	FetchPlaylist(id string) ([]models.Song, error)
+}
+
+type Registry struct {
+	providers []MediaProvider
+}
+
+func NewRegistry(providers ...MediaProvider) *Registry {
+	return &Registry{providers: providers}
+}
+
+// Match returns the first provider supporting the url together with id of the media
+func (r *Registry) Match(url string) (MediaProvider, string) {
+	for _, provider := range r.providers {
+		if id := provider.MatchUrl(url); id != "" {
+			return provider, id
+		}
+	}
+
+	return nil, ""
+}
+
+// MatchPlaylist returns the first provider supporting the playlist url together with id of the playlist
+func (r *Registry) MatchPlaylist(url string) (PlaylistProvider, string) {
+	for _, provider := range r.providers {
+		playlistProvider, ok := provider.(PlaylistProvider)
+		if !ok {
+			continue
+		}
+
+		if id := playlistProvider.MatchPlaylistUrl(url); id != "" {
+			return playlistProvider, id
+		}
+	}
+
+	return nil, ""
+}
+
+func (r *Registry) Get(name string) (MediaProvider, bool) {
+	for _, provider := range r.providers {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+
+	return nil, false
+}
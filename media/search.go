@@ -0,0 +1,101 @@
+package media
+
+import (
+	"errors"
+	"nbeat-api/models"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// SearchResultsLimit is the number of songs returned by a search
+const SearchResultsLimit = 10
+
+var ErrSearchUnsupported = errors.New("provider doesn't support search")
+
+// Searcher is implemented by providers which can find songs by text query
+type Searcher interface {
+	// Search returns songs matching the query, best matches first
+	Search(query string, limit int) ([]models.Song, error)
+}
+
+// SearchCache keeps search results in memory for TTL, so repeated searches don't hit the provider
+type SearchCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]searchEntry
+	group   singleflight.Group
+}
+
+type searchEntry struct {
+	songs     []models.Song
+	expiresAt time.Time
+}
+
+func NewSearchCache(ttl time.Duration) *SearchCache {
+	return &SearchCache{TTL: ttl, entries: make(map[string]searchEntry)}
+}
+
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+func (c *SearchCache) get(key string) ([]models.Song, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[key]
+	if !exists || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.songs, true
+}
+
+func (c *SearchCache) set(key string, songs []models.Song) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = searchEntry{songs: songs, expiresAt: now.Add(c.TTL)}
+}
+
+// Search returns songs of the provider matching the query
+func (c *SearchCache) Search(provider MediaProvider, query string) ([]models.Song, error) {
+	searcher, ok := provider.(Searcher)
+	if !ok {
+		return nil, ErrSearchUnsupported
+	}
+
+	query = normalizeQuery(query)
+	key := provider.Name() + ":" + query
+
+	if songs, exists := c.get(key); exists {
+		return songs, nil
+	}
+
+	songs, err, _ := c.group.Do(key, func() (interface{}, error) {
+		songs, err := searcher.Search(query, SearchResultsLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		c.set(key, songs)
+
+		return songs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return songs.([]models.Song), nil
+}
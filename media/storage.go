@@ -0,0 +1,55 @@
+package media
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Storage keeps uploaded files
+type Storage interface {
+	Save(name string, content io.Reader) error
+	Open(name string) (io.ReadSeekCloser, error)
+	Remove(name string) error
+}
+
+// DiskStorage keeps files in the directory on local disk
+type DiskStorage struct {
+	Dir string
+}
+
+func (s *DiskStorage) path(name string) string {
+	return filepath.Join(s.Dir, filepath.Base(name))
+}
+
+// Save writes the file to a temporary one first, so incomplete uploads are never visible
+func (s *DiskStorage) Save(name string, content io.Reader) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), s.path(name))
+}
+
+func (s *DiskStorage) Open(name string) (io.ReadSeekCloser, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *DiskStorage) Remove(name string) error {
+	return os.Remove(s.path(name))
+}
@@ -0,0 +1,57 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiskStorage(t *testing.T) {
+	storage := &DiskStorage{Dir: filepath.Join(t.TempDir(), "uploads")}
+
+	if err := storage.Save("song.mp3", strings.NewReader("content")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	file, err := storage.Open("song.mp3")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "content" {
+		t.Errorf("content = %q, want %q", content, "content")
+	}
+
+	// temporary files aren't left behind
+	entries, err := os.ReadDir(storage.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+
+	// names can't point outside of the directory
+	if err := storage.Save("../escaped.mp3", strings.NewReader("content")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storage.Dir, "escaped.mp3")); err != nil {
+		t.Errorf("file saved outside of the directory: %v", err)
+	}
+
+	if err := storage.Remove("song.mp3"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := storage.Open("song.mp3"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open() after Remove() error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
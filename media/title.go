@@ -0,0 +1,77 @@
+package media
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// noiseRegex matches bracketed tags like "(Official Video)" or "[HD]"
+	noiseRegex         = regexp.MustCompile(`(?i)\s*[(\[][^)\]]*\b(official|video|audio|lyrics?|hd|hq|4k|remaster(ed)?|visuali[sz]er|mv|explicit|clean|color coded)\b[^)\]]*[)\]]`)
+	trailingNoiseRegex = regexp.MustCompile(`(?i)\s+(m/v|mv|official (music )?video|lyrics?)$`)
+	// featRegex matches featured artists, bracketed or until the end of the text
+	featRegex      = regexp.MustCompile(`(?i)\s*(?:[(\[]\s*(?:feat\.?|ft\.?|featuring)\s([^)\]]*)[)\]]|\s(?:feat\.?|ft\.?|featuring)\s(.*)$)`)
+	separatorRegex = regexp.MustCompile(`\s+[-–—~|]\s+`)
+	// uploaderNoiseRegex matches suffixes of auto-generated and label channels
+	uploaderNoiseRegex = regexp.MustCompile(`(?i)(\s*-\s*topic|vevo|\s+official)$`)
+	vevoRegex          = regexp.MustCompile(`(?i)vevo$`)
+	camelCaseRegex     = regexp.MustCompile(`(\p{Ll})(\p{Lu})`)
+)
+
+// TitleInfo is metadata parsed from a song title
+type TitleInfo struct {
+	Artist string
+	Track  string
+	// Featuring lists featured artists as the title names them (e.g. "Pharrell Williams")
+	Featuring string
+	// Variant is the part of the title after the track telling which recording it is (e.g. "Live at Wembley")
+	Variant string
+}
+
+// NormalizeTitle parses titles like "Artist - Track (feat. Other) - Live (Official Video)".
+// Noise tags are left out, uploader is used as the artist when the title doesn't name one.
+func NormalizeTitle(title, uploader string) TitleInfo {
+	title = noiseRegex.ReplaceAllString(title, "")
+	title = trailingNoiseRegex.ReplaceAllString(title, "")
+
+	parts := separatorRegex.Split(title, 3)
+	if len(parts) == 1 {
+		parts = []string{"", title}
+	}
+
+	var featuring []string
+	for i, part := range parts {
+		for _, match := range featRegex.FindAllStringSubmatch(part, -1) {
+			featuring = append(featuring, cleanTitlePart(match[1]+match[2]))
+		}
+		parts[i] = cleanTitlePart(featRegex.ReplaceAllString(part, ""))
+	}
+
+	info := TitleInfo{Artist: parts[0], Track: parts[1], Featuring: strings.Join(featuring, ", ")}
+	if len(parts) == 3 {
+		info.Variant = parts[2]
+	}
+
+	if info.Artist == "" {
+		info.Artist = uploaderArtist(uploader)
+	}
+
+	return info
+}
+
+// uploaderArtist returns the artist's name from the name of their channel,
+// VEVO channels join words of the name together (e.g. "RickAstleyVEVO")
+func uploaderArtist(uploader string) string {
+	artist := strings.TrimSpace(uploaderNoiseRegex.ReplaceAllString(uploader, ""))
+	if vevoRegex.MatchString(uploader) && !strings.Contains(artist, " ") {
+		artist = camelCaseRegex.ReplaceAllString(artist, "$1 $2")
+	}
+
+	return artist
+}
+
+func cleanTitlePart(part string) string {
+	part = strings.Join(strings.Fields(part), " ")
+
+	return strings.Trim(part, `"'“”‘’ `)
+}
@@ -0,0 +1,38 @@
+package media
+
+import "testing"
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title    string
+		uploader string
+		want     TitleInfo
+	}{
+		{"Rick Astley - Never Gonna Give You Up (Official Video)", "Rick Astley", TitleInfo{Artist: "Rick Astley", Track: "Never Gonna Give You Up"}},
+		{"Daft Punk - Get Lucky [Official Audio] (HD)", "Daft Punk", TitleInfo{Artist: "Daft Punk", Track: "Get Lucky"}},
+		{"Daft Punk feat. Pharrell Williams - Get Lucky", "Daft Punk", TitleInfo{Artist: "Daft Punk", Track: "Get Lucky", Featuring: "Pharrell Williams"}},
+		{"Daft Punk ft. Pharrell Williams - Get Lucky", "Daft Punk", TitleInfo{Artist: "Daft Punk", Track: "Get Lucky", Featuring: "Pharrell Williams"}},
+		{"Daft Punk - Get Lucky (feat. Pharrell Williams)", "Daft Punk", TitleInfo{Artist: "Daft Punk", Track: "Get Lucky", Featuring: "Pharrell Williams"}},
+		{"Daft Punk - Get Lucky ft. Pharrell Williams", "Daft Punk", TitleInfo{Artist: "Daft Punk", Track: "Get Lucky", Featuring: "Pharrell Williams"}},
+		{"Artist ft. One - Track (feat. Two)", "Uploader", TitleInfo{Artist: "Artist", Track: "Track", Featuring: "One, Two"}},
+		{"Artist | Track", "Uploader", TitleInfo{Artist: "Artist", Track: "Track"}},
+		{"Artist ~ Track", "Uploader", TitleInfo{Artist: "Artist", Track: "Track"}},
+		{"Artist - Track - Live at Wembley", "Uploader", TitleInfo{Artist: "Artist", Track: "Track", Variant: "Live at Wembley"}},
+		{"Artist - Track (feat. Other) - Acoustic Version (Official Video)", "Uploader", TitleInfo{Artist: "Artist", Track: "Track", Featuring: "Other", Variant: "Acoustic Version"}},
+		{"\"Track\" lyrics", "Uploader", TitleInfo{Artist: "Uploader", Track: "Track"}},
+		{"Track ft. Other", "Uploader", TitleInfo{Artist: "Uploader", Track: "Track", Featuring: "Other"}},
+		{"Never Gonna Give You Up", "RickAstleyVEVO", TitleInfo{Artist: "Rick Astley", Track: "Never Gonna Give You Up"}},
+		{"Never Gonna Give You Up", "Rick Astley - Topic", TitleInfo{Artist: "Rick Astley", Track: "Never Gonna Give You Up"}},
+		{"Bad Guy", "Billie Eilish VEVO", TitleInfo{Artist: "Billie Eilish", Track: "Bad Guy"}},
+		{"Strobe", "deadmau5", TitleInfo{Artist: "deadmau5", Track: "Strobe"}},
+		{"Track-Name", "Uploader", TitleInfo{Artist: "Uploader", Track: "Track-Name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title+"/"+tt.uploader, func(t *testing.T) {
+			if got := NormalizeTitle(tt.title, tt.uploader); got != tt.want {
+				t.Errorf("NormalizeTitle(%q, %q) = %+v, want %+v", tt.title, tt.uploader, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"nbeat-api/models"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const ProviderUpload = "upload"
+
+var uploadUrlRegex = regexp.MustCompile(`/api/upload/([0-9a-f]{24})\b`)
+
+// Uploads is the provider of audio files uploaded by users, their metadata is kept in upload collection
+// and content in Storage
+type Uploads struct {
+	Db      *mongo.Database
+	Storage Storage
+}
+
+func (u *Uploads) Name() string {
+	return ProviderUpload
+}
+
+// MatchUrl matches urls of the endpoint streaming uploaded files
+func (u *Uploads) MatchUrl(url string) string {
+	if match := uploadUrlRegex.FindStringSubmatch(url); match != nil {
+		return match[1]
+	}
+
+	return ""
+}
+
+func (u *Uploads) FetchSong(id string) (models.Song, error) {
+	upload, err := u.Find(id)
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	return upload.Song, nil
+}
+
+func (u *Uploads) Find(id string) (models.Upload, error) {
+	objId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Upload{}, ErrNotFound
+	}
+
+	var upload models.Upload
+	err = u.Db.Collection("upload").FindOne(context.Background(), bson.M{"_id": objId}).Decode(&upload)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Upload{}, ErrNotFound
+	}
+
+	return upload, err
+}
+
+// Save stores the file, reads its duration and tags and returns the saved upload.
+// Files which aren't supported audio are removed.
+func (u *Uploads) Save(owner, fileName string, content io.Reader) (models.Upload, error) {
+	upload := models.Upload{
+		Id:        primitive.NewObjectID(),
+		Owner:     owner,
+		FileName:  filepath.Base(fileName),
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	name := upload.Id.Hex()
+
+	if err := u.Storage.Save(name, content); err != nil {
+		return models.Upload{}, err
+	}
+
+	info, size, err := u.readInfo(name)
+	if err != nil {
+		if err := u.Storage.Remove(name); err != nil {
+			return models.Upload{}, err
+		}
+		return models.Upload{}, err
+	}
+
+	title := info.Title
+	if title == "" {
+		title = strings.TrimSuffix(upload.FileName, filepath.Ext(upload.FileName))
+	}
+	if info.Artist != "" {
+		title = info.Artist + " - " + title
+	}
+
+	// tags are more reliable than parsed title
+	parsed := NormalizeTitle(title, "")
+	if info.Artist != "" && info.Title != "" {
+		parsed.Artist, parsed.Track = info.Artist, info.Title
+	}
+
+	upload.ContentType = info.ContentType
+	upload.Size = size
+	upload.Song = models.Song{
+		SongId:     name,
+		Provider:   ProviderUpload,
+		Duration:   info.Duration,
+		Title:      title,
+		Artist:     parsed.Artist,
+		Track:      parsed.Track,
+		Featuring:  parsed.Featuring,
+		Variant:    parsed.Variant,
+		UploaderId: owner,
+	}
+
+	if _, err := u.Db.Collection("upload").InsertOne(context.Background(), upload); err != nil {
+		return models.Upload{}, err
+	}
+
+	return upload, nil
+}
+
+func (u *Uploads) readInfo(name string) (AudioInfo, int64, error) {
+	file, err := u.Storage.Open(name)
+	if err != nil {
+		return AudioInfo{}, 0, err
+	}
+	defer file.Close()
+
+	info, err := ReadAudioInfo(file)
+	if err != nil {
+		return AudioInfo{}, 0, err
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+
+	return info, size, err
+}
+
+// Open returns the upload together with its content
+func (u *Uploads) Open(id string) (models.Upload, io.ReadSeekCloser, error) {
+	upload, err := u.Find(id)
+	if err != nil {
+		return models.Upload{}, nil, err
+	}
+
+	file, err := u.Storage.Open(upload.Id.Hex())
+	if err != nil {
+		return models.Upload{}, nil, err
+	}
+
+	return upload, file, nil
+}
@@ -0,0 +1,429 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"nbeat-api/helper"
+	"nbeat-api/models"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ProviderYouTube = "youtube"
+
+const YouTubeBaseUrl = "https://www.googleapis.com/youtube/v3"
+
+const (
+	// youtubePageSize is the maximum number of items the API returns in one page or accepts in one request
+	youtubePageSize = 50
+	// YouTubePlaylistLimit is the maximum number of songs taken from a playlist
+	YouTubePlaylistLimit = 200
+)
+
+// YouTube is a client of YouTube Data API. BaseUrl can be pointed at a fake server,
+// failed requests (network errors, 5xx responses) are retried MaxRetries times with exponential backoff.
+type YouTube struct {
+	ApiKey  string
+	BaseUrl string
+	// Region is ISO 3166-1 alpha-2 code of the country listeners are in, used to detect blocked videos.
+	// When empty, region restrictions aren't checked.
+	Region       string
+	Client       *http.Client
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+func NewYouTube(apiKey string) *YouTube {
+	return &YouTube{
+		ApiKey:       apiKey,
+		BaseUrl:      YouTubeBaseUrl,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:   3,
+		RetryBackoff: 200 * time.Millisecond,
+	}
+}
+
+type youtubeVideo struct {
+	Id             string `json:"id"`
+	ContentDetails struct {
+		Duration          string `json:"duration"`
+		RegionRestriction struct {
+			Allowed []string `json:"allowed"`
+			Blocked []string `json:"blocked"`
+		} `json:"regionRestriction"`
+		ContentRating struct {
+			YtRating string `json:"ytRating"`
+		} `json:"contentRating"`
+	} `json:"contentDetails"`
+	Status struct {
+		Embeddable bool `json:"embeddable"`
+	} `json:"status"`
+	Snippet struct {
+		LiveBroadcastContent string `json:"liveBroadcastContent"`
+		Title                string `json:"title"`
+		ChannelTitle         string `json:"channelTitle"`
+		ChannelId            string `json:"channelId"`
+		Thumbnails           struct {
+			Default struct {
+				Url string `json:"url"`
+			} `json:"default"`
+		} `json:"thumbnails"`
+	} `json:"snippet"`
+}
+
+type youtubeVideoData struct {
+	Items []youtubeVideo `json:"items"`
+}
+
+type youtubeSearchData struct {
+	Items []struct {
+		Id struct {
+			VideoId string `json:"videoId"`
+		} `json:"id"`
+	} `json:"items"`
+}
+
+type youtubePlaylistItemsData struct {
+	NextPageToken string `json:"nextPageToken"`
+	Items         []struct {
+		ContentDetails struct {
+			VideoId string `json:"videoId"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+}
+
+type youtubeErrorData struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+func (y *YouTube) Name() string {
+	return ProviderYouTube
+}
+
+func (y *YouTube) MatchUrl(url string) string {
+	return helper.MatchSongUrl(url)
+}
+
+func (y *YouTube) FetchSong(id string) (models.Song, error) {
+	data, err := y.fetchVideoData(id)
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	return buildSongFromYoutubeData(data, y.Region)
+}
+
+// Search finds videos matching the query, search results don't contain durations
+// so videos are fetched afterwards in a single request
+func (y *YouTube) Search(query string, limit int) ([]models.Song, error) {
+	var results youtubeSearchData
+
+	params := url.Values{
+		"q":          {query},
+		"part":       {"snippet"},
+		"type":       {"video"},
+		"maxResults": {strconv.Itoa(limit)},
+	}
+
+	if err := y.get("search", params, &results); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(results.Items))
+	for _, item := range results.Items {
+		ids = append(ids, item.Id.VideoId)
+	}
+
+	return y.fetchSongs(ids)
+}
+
+func (y *YouTube) MatchPlaylistUrl(url string) string {
+	return helper.MatchPlaylistUrl(url)
+}
+
+// FetchPlaylist pages through items of the playlist and fetches their videos in batches,
+// private and deleted videos are left out
+func (y *YouTube) FetchPlaylist(id string) ([]models.Song, error) {
+	var ids []string
+
+	pageToken := ""
+	for len(ids) < YouTubePlaylistLimit {
+		var data youtubePlaylistItemsData
+
+		params := url.Values{
+			"playlistId": {id},
+			"part":       {"contentDetails"},
+			"maxResults": {strconv.Itoa(youtubePageSize)},
+		}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		if err := y.get("playlistItems", params, &data); err != nil {
+			return nil, err
+		}
+
+		for _, item := range data.Items {
+			ids = append(ids, item.ContentDetails.VideoId)
+		}
+
+		pageToken = data.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	if len(ids) > YouTubePlaylistLimit {
+		ids = ids[:YouTubePlaylistLimit]
+	}
+
+	songs, err := y.FetchSongs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(songs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return songs, nil
+}
+
+// FetchSongs fetches videos in batches of the maximum page size
+func (y *YouTube) FetchSongs(ids []string) ([]models.Song, error) {
+	songs := make([]models.Song, 0, len(ids))
+	for start := 0; start < len(ids); start += youtubePageSize {
+		batch, err := y.fetchSongs(ids[start:min(start+youtubePageSize, len(ids))])
+		if err != nil {
+			return nil, err
+		}
+
+		songs = append(songs, batch...)
+	}
+
+	return songs, nil
+}
+
+// fetchSongs fetches videos with the given ids in one request and returns them in the same order
+func (y *YouTube) fetchSongs(ids []string) ([]models.Song, error) {
+	if len(ids) == 0 {
+		return []models.Song{}, nil
+	}
+
+	data, err := y.fetchVideoData(strings.Join(ids, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	// videos endpoint doesn't keep the order of ids
+	videos := make(map[string]youtubeVideo, len(data.Items))
+	for _, video := range data.Items {
+		videos[video.Id] = video
+	}
+
+	songs := make([]models.Song, 0, len(ids))
+	for _, id := range ids {
+		video, exists := videos[id]
+		if !exists {
+			continue
+		}
+
+		song, err := buildSongFromYoutubeVideo(video, y.Region)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		songs = append(songs, song)
+	}
+
+	return songs, nil
+}
+
+func (y *YouTube) fetchVideoData(id string) (youtubeVideoData, error) {
+	var data youtubeVideoData
+
+	params := url.Values{
+		"id":   {id},
+		"part": {"snippet,contentDetails,status"},
+	}
+
+	err := y.get("videos", params, &data)
+
+	return data, err
+}
+
+// get requests the API endpoint and decodes the response into data
+func (y *YouTube) get(endpoint string, params url.Values, data interface{}) error {
+	if y.ApiKey == "" {
+		return ErrInvalidKey
+	}
+
+	baseUrl := y.BaseUrl
+	if baseUrl == "" {
+		baseUrl = YouTubeBaseUrl
+	}
+	requestUrl := fmt.Sprintf("%s/%s?%s", strings.TrimSuffix(baseUrl, "/"), endpoint, params.Encode())
+
+	backoff := y.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, err := y.request(requestUrl)
+		if err == nil {
+			if err := json.Unmarshal(body, data); err != nil {
+				return fmt.Errorf("youtube: invalid response: %w", err)
+			}
+			return nil
+		}
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) || attempt >= y.MaxRetries {
+			return err
+		}
+
+		log.Printf("youtube: request failed, retrying in %s: %v", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryableError wraps errors of requests which may succeed when repeated
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// request sends the key in a header, so it doesn't end up in logged errors which include the url
+func (y *YouTube) request(requestUrl string) ([]byte, error) {
+	client := y.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("youtube: %w", err)
+	}
+	req.Header.Set("X-Goog-Api-Key", y.ApiKey)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, &retryableError{fmt.Errorf("youtube: %w", err)}
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, &retryableError{fmt.Errorf("youtube: %w", err)}
+	}
+
+	if res.StatusCode == http.StatusOK {
+		return body, nil
+	}
+
+	err = youtubeError(res.StatusCode, body)
+	if res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests {
+		return nil, &retryableError{err}
+	}
+
+	return nil, err
+}
+
+// youtubeError converts error response of the API into one of provider errors
+func youtubeError(status int, body []byte) error {
+	var data youtubeErrorData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return fmt.Errorf("youtube: unexpected status %d", status)
+	}
+
+	for _, e := range data.Error.Errors {
+		switch e.Reason {
+		case "quotaExceeded", "dailyLimitExceeded", "rateLimitExceeded", "userRateLimitExceeded":
+			return fmt.Errorf("%w: %s", ErrQuotaExceeded, data.Error.Message)
+		case "keyInvalid", "keyExpired", "forbidden", "accessNotConfigured":
+			return fmt.Errorf("%w: %s", ErrInvalidKey, data.Error.Message)
+		case "videoNotFound", "notFound", "playlistNotFound":
+			return fmt.Errorf("%w: %s", ErrNotFound, data.Error.Message)
+		}
+	}
+
+	if status == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, data.Error.Message)
+	}
+
+	return fmt.Errorf("youtube: unexpected status %d: %s", status, data.Error.Message)
+}
+
+func buildSongFromYoutubeData(data youtubeVideoData, region string) (models.Song, error) {
+	if len(data.Items) == 0 {
+		return models.Song{}, ErrNotFound
+	}
+
+	return buildSongFromYoutubeVideo(data.Items[0], region)
+}
+
+// restrictions returns reasons why the video may not play in embedded players in the region
+func (d youtubeVideo) restrictions(region string) []string {
+	var restrictions []string
+
+	if d.Snippet.LiveBroadcastContent == "live" || d.Snippet.LiveBroadcastContent == "upcoming" {
+		restrictions = append(restrictions, models.SongLive)
+	}
+
+	if !d.Status.Embeddable {
+		restrictions = append(restrictions, models.SongNotEmbeddable)
+	}
+
+	allowed, blocked := d.ContentDetails.RegionRestriction.Allowed, d.ContentDetails.RegionRestriction.Blocked
+	if region != "" && (slices.Contains(blocked, region) || allowed != nil && !slices.Contains(allowed, region)) {
+		restrictions = append(restrictions, models.SongRegionBlocked)
+	}
+
+	if d.ContentDetails.ContentRating.YtRating == "ytAgeRestricted" {
+		restrictions = append(restrictions, models.SongAgeRestricted)
+	}
+
+	return restrictions
+}
+
+func buildSongFromYoutubeVideo(d youtubeVideo, region string) (models.Song, error) {
+	songDuration, err := helper.ParseISODuration(d.ContentDetails.Duration)
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	info := NormalizeTitle(d.Snippet.Title, d.Snippet.ChannelTitle)
+
+	return models.Song{
+		SongId:       d.Id,
+		Provider:     ProviderYouTube,
+		Duration:     songDuration.Seconds(),
+		Title:        d.Snippet.Title,
+		Artist:       info.Artist,
+		Track:        info.Track,
+		Featuring:    info.Featuring,
+		Variant:      info.Variant,
+		UploaderId:   d.Snippet.ChannelId,
+		Thumbnail:    d.Snippet.Thumbnails.Default.Url,
+		Restrictions: d.restrictions(region),
+	}, nil
+}
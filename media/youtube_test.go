@@ -0,0 +1,165 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"nbeat-api/models"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testVideoResponse = `{"items": [{
+	"id": "dQw4w9WgXcQ",
+	"contentDetails": {"duration": "PT3M33S"},
+	"status": {"embeddable": true},
+	"snippet": {"title": "Rick Astley - Never Gonna Give You Up", "channelTitle": "Rick Astley", "channelId": "UCuAXFkgsw1L7xaCfnd5JJOw"}
+}]}`
+
+func apiError(reason string) string {
+	return `{"error": {"code": 403, "message": "` + reason + `", "errors": [{"reason": "` + reason + `"}]}}`
+}
+
+// newTestYouTube returns a client of a fake server which replies with the given responses in turn,
+// the last one is repeated
+func newTestYouTube(t *testing.T, statuses []int, bodies []string) (*YouTube, *atomic.Int32) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Goog-Api-Key"); got != "test-key" {
+			t.Errorf("X-Goog-Api-Key = %q, want %q", got, "test-key")
+		}
+		if r.URL.Query().Has("key") {
+			t.Errorf("key sent in query: %s", r.URL.RawQuery)
+		}
+
+		idx := min(int(requests.Add(1))-1, len(statuses)-1)
+		w.WriteHeader(statuses[idx])
+		w.Write([]byte(bodies[idx]))
+	}))
+	t.Cleanup(server.Close)
+
+	youtube := NewYouTube("test-key")
+	youtube.BaseUrl = server.URL
+	youtube.RetryBackoff = 0
+
+	return youtube, &requests
+}
+
+func TestYouTubeFetchSong(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		bodies       []string
+		wantErr      error
+		wantFail     bool
+		wantRequests int32
+	}{
+		{"ok", []int{200}, []string{testVideoResponse}, nil, false, 1},
+		{"retries server errors", []int{503, 500, 200}, []string{"", "", testVideoResponse}, nil, false, 3},
+		{"gives up after max retries", []int{503}, []string{""}, nil, true, 4},
+		{"quota exceeded", []int{403}, []string{apiError("quotaExceeded")}, ErrQuotaExceeded, true, 1},
+		{"invalid key", []int{400}, []string{apiError("keyInvalid")}, ErrInvalidKey, true, 1},
+		{"not found", []int{200}, []string{`{"items": []}`}, ErrNotFound, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			youtube, requests := newTestYouTube(t, tt.statuses, tt.bodies)
+
+			song, err := youtube.FetchSong("dQw4w9WgXcQ")
+			if tt.wantFail {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("FetchSong() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("FetchSong() error = %v", err)
+			} else if song.SongId != "dQw4w9WgXcQ" || song.Duration != 213 {
+				t.Errorf("FetchSong() = %+v", song)
+			}
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestYouTubeFetchSongs(t *testing.T) {
+	youtube, requests := newTestYouTube(t, []int{200}, []string{testVideoResponse})
+
+	ids := make([]string, 0, 120)
+	ids = append(ids, "dQw4w9WgXcQ")
+	for len(ids) < cap(ids) {
+		ids = append(ids, fmt.Sprintf("missing%04d", len(ids)))
+	}
+
+	songs, err := youtube.FetchSongs(ids)
+	if err != nil {
+		t.Fatalf("FetchSongs() error = %v", err)
+	}
+
+	// the fake server returns the same video for every batch, only the one asking for it keeps it
+	if len(songs) != 1 || songs[0].UploaderId != "UCuAXFkgsw1L7xaCfnd5JJOw" {
+		t.Errorf("FetchSongs() = %+v", songs)
+	}
+
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestYouTubeErrorDoesNotLeakKey(t *testing.T) {
+	youtube := NewYouTube("secret-key")
+	// nothing listens on the port, so the request fails with *url.Error
+	youtube.BaseUrl = "http://127.0.0.1:1"
+	youtube.MaxRetries = 0
+
+	_, err := youtube.FetchSong("dQw4w9WgXcQ")
+	if err == nil {
+		t.Fatal("FetchSong() succeeded, want error")
+	}
+
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("error contains the api key: %v", err)
+	}
+}
+
+func TestYouTubeWithoutKey(t *testing.T) {
+	if _, err := NewYouTube("").FetchSong("dQw4w9WgXcQ"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("FetchSong() error = %v, want %v", err, ErrInvalidKey)
+	}
+}
+
+func TestYouTubeRegionRestrictions(t *testing.T) {
+	tests := []struct {
+		name    string
+		region  string
+		allowed []string
+		blocked []string
+		want    bool
+	}{
+		{"no region configured", "", nil, []string{"DE"}, false},
+		{"no region configured with allow list", "", []string{"US"}, nil, false},
+		{"blocked in region", "DE", nil, []string{"DE"}, true},
+		{"blocked elsewhere", "PL", nil, []string{"DE"}, false},
+		{"allowed in region", "US", []string{"US"}, nil, false},
+		{"not on allow list", "PL", []string{"US"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var video youtubeVideo
+			video.Status.Embeddable = true
+			video.ContentDetails.RegionRestriction.Allowed = tt.allowed
+			video.ContentDetails.RegionRestriction.Blocked = tt.blocked
+
+			if got := slices.Contains(video.restrictions(tt.region), models.SongRegionBlocked); got != tt.want {
+				t.Errorf("region blocked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
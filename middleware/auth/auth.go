@@ -78,6 +78,21 @@ func ExtractClaimsFromContext(c *gin.Context) *SignedClaims {
 	return ExtractClaims(token)
 }
 
+// ExtractOptionalClaims returns claims of the access token from the request, or nil if it doesn't have a valid one
+func ExtractOptionalClaims(c *gin.Context) *SignedClaims {
+	token := c.GetHeader("Authorization")
+	if !strings.HasPrefix(token, "Bearer ") {
+		return nil
+	}
+
+	claims, err := ValidateToken(token[len("Bearer "):])
+	if err != nil || claims.Token == "refresh" {
+		return nil
+	}
+
+	return claims
+}
+
 func ValidateToken(signedToken string) (claims *SignedClaims, err error) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"slices"
+	"strings"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -13,8 +16,73 @@ type Channel struct {
 	LastSongPLayedAt int64     `json:"last_song_played_at,omitempty" bson:"last_song_played_at"`
 	Messages         []Message `json:"messages" bson:"messages"`
 	Owner            string    `json:"owner,omitempty"`
+	DeletedAt        int64     `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	Moderators       []string  `json:"moderators,omitempty" bson:"moderators,omitempty"`
+	DJs              []string  `json:"djs,omitempty" bson:"djs,omitempty"`
+	Settings         Settings  `json:"settings" bson:"settings"`
+	Blocklist        Blocklist `json:"blocklist" bson:"blocklist"`
+}
+
+// Blocklist of songs which can't be played in the channel. Uploaders are ids of channels (or users)
+// the songs were published by, keywords are matched case-insensitively against titles.
+type Blocklist struct {
+	SongIds   []string `json:"song_ids" bson:"song_ids"`
+	Uploaders []string `json:"uploaders" bson:"uploaders"`
+	Keywords  []string `json:"keywords" bson:"keywords"`
+}
+
+const (
+	BlockedSong     = "song"
+	BlockedUploader = "uploader"
+	BlockedKeyword  = "keyword"
+)
+
+type Settings struct {
+	// SkipThreshold is the fraction of authenticated listeners that have to vote
+	// for the current song to be skipped, nil means DefaultSkipThreshold and 0 skips on any single vote
+	SkipThreshold *float64 `json:"skip_threshold,omitempty" bson:"skip_threshold,omitempty" validate:"omitempty,gte=0,lte=1"`
+
+	// Queue limits, 0 means no limit. Owner and DJs are not limited.
+	MaxPendingSongsPerUser int `json:"max_pending_songs_per_user" bson:"max_pending_songs_per_user" validate:"gte=0"`
+	// MaxSongDuration is in seconds
+	MaxSongDuration float64 `json:"max_song_duration" bson:"max_song_duration" validate:"gte=0"`
+	MaxQueueLength  int     `json:"max_queue_length" bson:"max_queue_length" validate:"gte=0"`
+
+	// RejectRestrictions lists restrictions of songs (e.g. SongLive) which can't be added to the queue,
+	// nil means DefaultRejectRestrictions. It applies to everyone, as clients can't play such songs.
+	RejectRestrictions []string `json:"reject_restrictions" bson:"reject_restrictions" validate:"dive,oneof=live not_embeddable region_blocked age_restricted"`
+
+	// ReplayCooldown is the time in seconds after which a song can be played again
+	ReplayCooldown int64 `json:"replay_cooldown" bson:"replay_cooldown" validate:"gte=0"`
+
+	Autoplay Autoplay `json:"autoplay" bson:"autoplay"`
+
+	// FairQueue interleaves upcoming songs round-robin by users who added them
+	FairQueue bool `json:"fair_queue" bson:"fair_queue"`
+
+	// ModeratedRequests makes songs from users other than DJs and moderators wait for approval
+	ModeratedRequests bool `json:"moderated_requests" bson:"moderated_requests"`
+}
+
+const (
+	// AutoplayHistory plays shuffled songs from the channel's history
+	AutoplayHistory = "history"
+	// AutoplayPlaylist plays songs of the saved playlist in order
+	AutoplayPlaylist = "playlist"
+	// AutoplayLiked plays the most liked songs of the channel
+	AutoplayLiked = "liked"
+)
+
+// Autoplay defines where songs come from when the queue runs dry, empty Source disables autoplay
+type Autoplay struct {
+	Source     string `json:"source" bson:"source" validate:"omitempty,oneof=history playlist liked"`
+	PlaylistId string `json:"playlist_id,omitempty" bson:"playlist_id,omitempty" validate:"required_if=Source playlist"`
 }
 
+const DefaultSkipThreshold = 0.5
+
+var DefaultRejectRestrictions = []string{SongLive, SongNotEmbeddable, SongRegionBlocked, SongAgeRestricted}
+
 type Message struct {
 	Author  string             `json:"author"`
 	Content string             `json:"content"`
@@ -28,6 +96,60 @@ func (c Channel) Validate() error {
 	return err
 }
 
+func (c Channel) IsModerator(userId string) bool {
+	return c.Owner == userId || slices.Contains(c.Moderators, userId)
+}
+
+func (c Channel) IsDJ(userId string) bool {
+	return c.Owner == userId || slices.Contains(c.DJs, userId)
+}
+
+// IsCurator tells whether the user can approve song requests
+func (c Channel) IsCurator(userId string) bool {
+	return c.IsDJ(userId) || c.IsModerator(userId)
+}
+
+// Match returns which kind of blocklist entry (e.g. BlockedSong) the song matches, or empty string
+func (b Blocklist) Match(song Song) string {
+	if slices.Contains(b.SongIds, song.SongId) {
+		return BlockedSong
+	}
+
+	if song.UploaderId != "" && slices.Contains(b.Uploaders, song.UploaderId) {
+		return BlockedUploader
+	}
+
+	title := strings.ToLower(song.Title)
+	for _, keyword := range b.Keywords {
+		if strings.Contains(title, strings.ToLower(keyword)) {
+			return BlockedKeyword
+		}
+	}
+
+	return ""
+}
+
+func (s Settings) Validate() error {
+	err := validate.Struct(s)
+	return err
+}
+
+func (s Settings) GetSkipThreshold() float64 {
+	if s.SkipThreshold == nil {
+		return DefaultSkipThreshold
+	}
+
+	return *s.SkipThreshold
+}
+
+func (s Settings) GetRejectRestrictions() []string {
+	if s.RejectRestrictions == nil {
+		return DefaultRejectRestrictions
+	}
+
+	return s.RejectRestrictions
+}
+
 func (m Message) Validate() error {
 	err := validate.Struct(m)
 	return err
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestGetSkipThreshold(t *testing.T) {
+	zero, custom := 0.0, 0.3
+
+	tests := []struct {
+		name      string
+		threshold *float64
+		want      float64
+	}{
+		{"unset uses default", nil, DefaultSkipThreshold},
+		{"zero skips on any vote", &zero, 0},
+		{"custom", &custom, 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{SkipThreshold: tt.threshold}
+			if got := settings.GetSkipThreshold(); got != tt.want {
+				t.Errorf("GetSkipThreshold() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PlaylistPublic  = "public"
+	PlaylistPrivate = "private"
+
+	MaxPlaylistSongs = 500
+)
+
+type Playlist struct {
+	Id         primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Name       string             `json:"name" bson:"name" validate:"min=1,max=50"`
+	Owner      string             `json:"owner" bson:"owner"`
+	Visibility string             `json:"visibility" bson:"visibility" validate:"oneof=public private"`
+	Songs      []Song             `json:"songs" bson:"songs"`
+}
+
+func (p Playlist) Validate() error {
+	err := validate.Struct(p)
+	return err
+}
+
+func (p Playlist) CanView(userId string) bool {
+	return p.Visibility == PlaylistPublic || p.Owner == userId
+}
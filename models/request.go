@@ -0,0 +1,14 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SongRequest is a song waiting for approval in a channel with moderated requests
+type SongRequest struct {
+	Id        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	ChannelId primitive.ObjectID `json:"channel_id" bson:"channel_id"`
+	UserId    string             `json:"user" bson:"user"`
+	CreatedAt int64              `json:"created_at" bson:"created_at"`
+	Song      Song               `json:"song" bson:"song"`
+}
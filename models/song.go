@@ -1,8 +1,11 @@
 package models
 
 import (
-	"nbeat-api/helper"
+	"errors"
+	"slices"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -10,45 +13,352 @@ type Queue struct {
 	Id        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
 	ChannelId primitive.ObjectID `json:"channel_id" bson:"channel_id"`
 	Songs     []Song
+	Playback  Playback `json:"playback" bson:"playback"`
+	// Version is incremented on every change of the queue, used to detect concurrent modifications
+	Version int64 `json:"-" bson:"version"`
+}
+
+const (
+	PlaybackPlaying = "playing"
+	PlaybackPaused  = "paused"
+)
+
+type Playback struct {
+	State    string `json:"state" bson:"state,omitempty"`
+	PausedAt int64  `json:"paused_at,omitempty" bson:"paused_at,omitempty"`
+}
+
+// PlaybackStatus describes what is playing in the channel at the moment
+type PlaybackStatus struct {
+	State string `json:"state"`
+	Song  *Song  `json:"song"`
+	// Position within the current song in ms, counted from its start offset
+	Position int64 `json:"position"`
+}
+
+// HistorySong is a song which finished playing, stored in song_history collection
+type HistorySong struct {
+	ChannelId primitive.ObjectID `json:"channel_id" bson:"channel_id"`
+	Song      `bson:",inline"`
 }
 
 type Song struct {
-	Id            primitive.ObjectID `json:"id" bson:"id"`
-	SongId        string             `json:"song_id" bson:"song_id"`
-	Duration      float64            `json:"duration" bson:"duration"`
-	Title         string             `json:"title" bson:"title"`
-	Thumbnail     string             `json:"thumbnail" bson:"thumbnail"`
-	SongStartTime int64              `json:"song_start_time" bson:"song_start_time"`
-}
-
-func BuildSongFromYoutubeData(data YoutubeVideoData) (Song, error) {
-	d := data.Items[0]
-	songDuration, err := helper.ParseISODuration(d.ContentDetails.Duration)
-	if err != nil {
-		return Song{}, err
-	}
-
-	return Song{
-		SongId:    d.Id,
-		Duration:  songDuration.Seconds(),
-		Title:     d.Snippet.Title,
-		Thumbnail: d.Snippet.Thumbnails.Default.Url,
-	}, nil
-}
-
-type YoutubeVideoData struct {
-	Items []struct {
-		Id             string `json:"id"`
-		ContentDetails struct {
-			Duration string `json:"duration"`
-		} `json:"contentDetails"`
-		Snippet struct {
-			Title      string `json:"title"`
-			Thumbnails struct {
-				Default struct {
-					Url string `json:"url"`
-				} `json:"default"`
-			} `json:"thumbnails"`
-		} `json:"snippet"`
-	} `json:"items"`
+	Id     primitive.ObjectID `json:"id" bson:"id"`
+	SongId string             `json:"song_id" bson:"song_id"`
+	// Provider is the name of the media provider the song comes from (e.g. "youtube")
+	Provider string  `json:"provider" bson:"provider"`
+	Duration float64 `json:"duration" bson:"duration"`
+	// StartOffset and EndOffset (s) limit the part of the media which is played,
+	// clients should begin playback at StartOffset
+	StartOffset float64 `json:"start_offset,omitempty" bson:"start_offset,omitempty"`
+	EndOffset   float64 `json:"end_offset,omitempty" bson:"end_offset,omitempty"`
+	// Title is kept as the provider returned it, Artist, Track, Featuring and Variant are parsed from it
+	Title     string `json:"title" bson:"title"`
+	Artist    string `json:"artist,omitempty" bson:"artist,omitempty"`
+	Track     string `json:"track,omitempty" bson:"track,omitempty"`
+	Featuring string `json:"featuring,omitempty" bson:"featuring,omitempty"`
+	Variant   string `json:"variant,omitempty" bson:"variant,omitempty"`
+	// UploaderId is the id of the channel (or user) which published the media
+	UploaderId    string `json:"uploader_id,omitempty" bson:"uploader_id,omitempty"`
+	Thumbnail     string `json:"thumbnail" bson:"thumbnail"`
+	SongStartTime int64  `json:"song_start_time" bson:"song_start_time"`
+	// SkippedAt is the unix timestamp (ms) at which the song was skipped
+	SkippedAt int64  `json:"skipped_at,omitempty" bson:"skipped_at,omitempty"`
+	AddedBy   string `json:"added_by,omitempty" bson:"added_by,omitempty"`
+	// Restrictions list reasons why clients may be unable to play the song (e.g. SongLive)
+	Restrictions []string `json:"restrictions,omitempty" bson:"restrictions,omitempty"`
+}
+
+// Song restrictions
+const (
+	SongLive          = "live"
+	SongNotEmbeddable = "not_embeddable"
+	SongRegionBlocked = "region_blocked"
+	SongAgeRestricted = "age_restricted"
+)
+
+var (
+	ErrSongNotQueued  = errors.New("song is not in upcoming songs")
+	ErrAlreadyPaused  = errors.New("playback is already paused")
+	ErrAlreadyPlaying = errors.New("playback is already playing")
+	ErrNothingPlaying = errors.New("nothing is playing")
+)
+
+// QueueClockExpr returns aggregation expression evaluating to the current time of the queue
+// (time when playback was paused or now), playback is the path to queue's playback field e.g. "$playback"
+func QueueClockExpr(playback string, now int64) bson.M {
+	return bson.M{"$cond": []interface{}{
+		bson.M{"$eq": []interface{}{playback + ".state", PlaybackPaused}},
+		playback + ".paused_at",
+		now,
+	}}
+}
+
+func (q *Queue) IsPaused() bool {
+	return q.Playback.State == PlaybackPaused
+}
+
+// Now returns the current time of the queue in ms, while paused the time stands still
+func (q *Queue) Now() int64 {
+	if q.IsPaused() {
+		return q.Playback.PausedAt
+	}
+
+	return time.Now().UnixMilli()
+}
+
+func (q *Queue) Pause(now int64) error {
+	if q.IsPaused() {
+		return ErrAlreadyPaused
+	}
+
+	q.Playback = Playback{State: PlaybackPaused, PausedAt: now}
+
+	return nil
+}
+
+// Resume moves current and upcoming songs by the time playback was paused for
+func (q *Queue) Resume(now int64) error {
+	if !q.IsPaused() {
+		return ErrAlreadyPlaying
+	}
+
+	pausedAt := q.Playback.PausedAt
+	for i := range q.Songs {
+		if q.Songs[i].EndTime() > pausedAt {
+			q.Songs[i].SongStartTime += now - pausedAt
+		}
+	}
+
+	q.Playback = Playback{State: PlaybackPlaying}
+
+	return nil
+}
+
+// SeekTo sets position (ms) within the current song, clamped to its duration.
+// Only the current and upcoming songs are moved.
+func (q *Queue) SeekTo(position int64, now int64) error {
+	current := q.CurrentSongIndex(now)
+	if current == -1 {
+		return ErrNothingPlaying
+	}
+
+	song := q.Songs[current]
+	position = min(max(position, 0), int64(song.PlayDuration()*1000))
+
+	offset := now - position - song.SongStartTime
+	for i := current; i < len(q.Songs); i++ {
+		q.Songs[i].SongStartTime += offset
+	}
+
+	return nil
+}
+
+func (q *Queue) Status() PlaybackStatus {
+	status := PlaybackStatus{State: PlaybackPlaying}
+	if q.IsPaused() {
+		status.State = PlaybackPaused
+	}
+
+	now := q.Now()
+	if current := q.CurrentSongIndex(now); current != -1 {
+		song := q.Songs[current]
+		status.Song = &song
+		status.Position = now - song.SongStartTime
+	}
+
+	return status
+}
+
+// EndTime returns the unix timestamp (ms) at which the song stops playing
+func (s Song) EndTime() int64 {
+	if s.SkippedAt != 0 {
+		return s.SkippedAt
+	}
+
+	return s.SongStartTime + int64(s.PlayDuration()*1000)
+}
+
+// PlayDuration returns how long (s) the song plays, between its start and end offsets
+func (s Song) PlayDuration() float64 {
+	end := s.Duration
+	if s.EndOffset != 0 {
+		end = s.EndOffset
+	}
+
+	return max(end-s.StartOffset, 0)
+}
+
+// SetOffsets sets the part of the media to play, offsets outside of the media are ignored
+func (s *Song) SetOffsets(start, end float64) {
+	if start > 0 && start < s.Duration {
+		s.StartOffset = start
+	}
+
+	if end > s.StartOffset && end < s.Duration {
+		s.EndOffset = end
+	}
+}
+
+// NewEntry returns copy of the song with a new id and without data about its place in a queue
+func (s Song) NewEntry() Song {
+	s.Id = primitive.NewObjectID()
+	s.SongStartTime = 0
+	s.SkippedAt = 0
+	s.AddedBy = ""
+
+	return s
+}
+
+// CurrentSongIndex returns the index of the song playing at the given time, or -1 if nothing is playing
+func (q *Queue) CurrentSongIndex(now int64) int {
+	for i, song := range q.Songs {
+		if song.SongStartTime <= now && now < song.EndTime() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Reschedule sets start times of songs from the given index so they play one after another,
+// starting at startTime
+func (q *Queue) Reschedule(from int, startTime int64) {
+	for i := from; i < len(q.Songs); i++ {
+		q.Songs[i].SongStartTime = startTime
+		startTime = q.Songs[i].EndTime()
+	}
+}
+
+// Upcoming returns the index of the first song that hasn't started yet and the time it can start at
+// (end of the current song, or now if nothing is playing)
+func (q *Queue) Upcoming(now int64) (int, int64) {
+	startTime := now
+	if current := q.CurrentSongIndex(now); current != -1 {
+		startTime = q.Songs[current].EndTime()
+	}
+
+	for i, song := range q.Songs {
+		if song.SongStartTime > now {
+			return i, startTime
+		}
+	}
+
+	return len(q.Songs), startTime
+}
+
+func (q *Queue) UpcomingSongs(now int64) []Song {
+	first, _ := q.Upcoming(now)
+	return q.Songs[first:]
+}
+
+// InsertFair inserts the song into the earliest slot among upcoming songs that keeps them
+// interleaved round-robin by users who added them, and returns the scheduled song
+func (q *Queue) InsertFair(song Song, now int64) Song {
+	first, startTime := q.Upcoming(now)
+
+	round := 0
+	for _, s := range q.Songs[first:] {
+		if s.AddedBy == song.AddedBy {
+			round++
+		}
+	}
+
+	position := first
+	rounds := make(map[string]int)
+	for i := first; i < len(q.Songs); i++ {
+		addedBy := q.Songs[i].AddedBy
+		if rounds[addedBy] <= round {
+			position = i + 1
+		}
+		rounds[addedBy]++
+	}
+
+	q.Songs = slices.Insert(q.Songs, position, song)
+	q.Reschedule(first, startTime)
+
+	return q.Songs[position]
+}
+
+// FindRecentSong returns the latest entry of the song which is still pending or started after since
+func (q *Queue) FindRecentSong(songId string, since int64, now int64) (Song, bool) {
+	for i := len(q.Songs) - 1; i >= 0; i-- {
+		song := q.Songs[i]
+		if song.SongId == songId && (song.EndTime() > now || song.SongStartTime >= since) {
+			return song, true
+		}
+	}
+
+	return Song{}, false
+}
+
+// DropPlayedSongs removes songs which finished playing from the queue and returns them
+func (q *Queue) DropPlayedSongs(now int64) []Song {
+	var played []Song
+	songs := make([]Song, 0, len(q.Songs))
+
+	for _, song := range q.Songs {
+		if song.EndTime() <= now {
+			played = append(played, song)
+		} else {
+			songs = append(songs, song)
+		}
+	}
+
+	q.Songs = songs
+
+	return played
+}
+
+func (q *Queue) upcomingSongIndex(id primitive.ObjectID, now int64) (int, int, int64) {
+	first, startTime := q.Upcoming(now)
+
+	for i := first; i < len(q.Songs); i++ {
+		if q.Songs[i].Id == id {
+			return i, first, startTime
+		}
+	}
+
+	return -1, first, startTime
+}
+
+// FindUpcomingSong returns the upcoming song with the given id
+func (q *Queue) FindUpcomingSong(id primitive.ObjectID, now int64) (Song, error) {
+	idx, _, _ := q.upcomingSongIndex(id, now)
+	if idx == -1 {
+		return Song{}, ErrSongNotQueued
+	}
+
+	return q.Songs[idx], nil
+}
+
+// RemoveSong removes the upcoming song with the given id and moves songs after it forward
+func (q *Queue) RemoveSong(id primitive.ObjectID, now int64) error {
+	idx, first, startTime := q.upcomingSongIndex(id, now)
+	if idx == -1 {
+		return ErrSongNotQueued
+	}
+
+	q.Songs = append(q.Songs[:idx], q.Songs[idx+1:]...)
+	q.Reschedule(first, startTime)
+
+	return nil
+}
+
+// MoveSong moves the upcoming song with the given id to the position within upcoming songs
+// (0 is the next song to play) and recomputes their start times
+func (q *Queue) MoveSong(id primitive.ObjectID, position int, now int64) error {
+	idx, first, startTime := q.upcomingSongIndex(id, now)
+	if idx == -1 {
+		return ErrSongNotQueued
+	}
+
+	song := q.Songs[idx]
+	q.Songs = append(q.Songs[:idx], q.Songs[idx+1:]...)
+
+	target := first + min(max(position, 0), len(q.Songs)-first)
+	q.Songs = slices.Insert(q.Songs, target, song)
+	q.Reschedule(first, startTime)
+
+	return nil
 }
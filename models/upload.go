@@ -0,0 +1,16 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upload is an audio file uploaded by the user, Song holds its metadata
+type Upload struct {
+	Id          primitive.ObjectID `json:"_id" bson:"_id"`
+	Owner       string             `json:"owner" bson:"owner"`
+	FileName    string             `json:"file_name" bson:"file_name"`
+	ContentType string             `json:"content_type" bson:"content_type"`
+	Size        int64              `json:"size" bson:"size"`
+	CreatedAt   int64              `json:"created_at" bson:"created_at"`
+	Song        Song               `json:"song" bson:"song"`
+}